@@ -7,6 +7,7 @@ import (
 	"github.com/raymondbutcher/ltf"
 	"github.com/raymondbutcher/ltf/internal/arguments"
 	internal "github.com/raymondbutcher/ltf/internal/ltf" // TODO: refactor this package away
+	"github.com/raymondbutcher/ltf/internal/redact"
 )
 
 func main() {
@@ -25,7 +26,7 @@ func main() {
 
 	_, exitStatus, err := internal.Run(cwd, args, env)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "%s: %s\n", args.Bin, err)
+		redact.Fprintf(os.Stderr, "%s: %s\n", args.Bin, err)
 	}
 
 	os.Exit(exitStatus)
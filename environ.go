@@ -40,3 +40,16 @@ func (env Environ) SetValue(name string, value string) Environ {
 	newEnv = append(newEnv, name+"="+value)
 	return newEnv
 }
+
+// UnsetValue removes an environment variable from the environment variables.
+// A new, updated Environ object is returned; it does not modify the existing object.
+func (env Environ) UnsetValue(name string) Environ {
+	newEnv := Environ{}
+	prefix := name + "="
+	for _, v := range env {
+		if !strings.HasPrefix(v, prefix) {
+			newEnv = append(newEnv, v)
+		}
+	}
+	return newEnv
+}
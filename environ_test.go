@@ -26,4 +26,10 @@ func TestEnviron(t *testing.T) {
 	is.Equal(env.GetValue("THREE"), "3")
 
 	is.Equal(len(env), 3)
+
+	env = env.UnsetValue("ONE")
+
+	is.Equal(env.GetValue("ONE"), "")
+	is.Equal(env.GetValue("TWO"), "changed")
+	is.Equal(len(env), 2)
 }
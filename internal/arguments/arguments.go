@@ -3,6 +3,7 @@ package arguments
 import (
 	"errors"
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/google/shlex"
@@ -78,6 +79,23 @@ func New(args []string, env ltf.Environ) (*Arguments, error) {
 	return &a, err
 }
 
+var safeArg = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// Quote joins arguments into a string, quoting them where necessary so that
+// the result can be safely used in a shell, e.g. `eval "set -- $LTF_ARGS"`,
+// or in the TF_CLI_ARGS and TF_CLI_ARGS_name environment variables.
+func Quote(args []string) string {
+	quoted := []string{}
+	for _, arg := range args {
+		if safeArg.MatchString(arg) {
+			quoted = append(quoted, arg)
+		} else {
+			quoted = append(quoted, "'"+strings.ReplaceAll(arg, "'", `'"'"'`)+"'")
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
 // clean converts `-var value` and `-var-file value` arguments
 // into `-var=value` and `-var-file=value` respectively.
 func clean(args []string) []string {
@@ -156,3 +156,16 @@ func TestArgumentsVersion(t *testing.T) {
 		Version:    true,
 	})
 }
+
+func TestQuote(t *testing.T) {
+	is := is.New(t)
+
+	is.Equal(Quote([]string{"plan", "-out=tfplan"}), "plan -out=tfplan")
+	is.Equal(Quote([]string{"-var", "x=it's"}), `-var 'x=it'"'"'s'`)
+	is.Equal(Quote([]string{""}), "''")
+
+	// The quoted arguments can be read from TF_CLI_ARGS.
+	combined, err := New([]string{"ltf", "plan"}, ltf.NewEnviron("TF_CLI_ARGS_plan="+Quote([]string{"-var=x=it's a test"})))
+	is.NoErr(err)
+	is.Equal(combined.Virtual, []string{"ltf", "plan", "-var=x=it's a test"})
+}
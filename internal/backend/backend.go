@@ -5,9 +5,9 @@ import (
 	"path"
 	"sort"
 
-	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/gohcl"
 	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/raymondbutcher/ltf/internal/environment"
 	"github.com/raymondbutcher/ltf/internal/filesystem"
 	"github.com/raymondbutcher/ltf/internal/variable"
 	"github.com/zclconf/go-cty/cty"
@@ -16,8 +16,9 @@ import (
 )
 
 // LoadConfiguration reads *.tfbackend files from the specified directories,
-// renders them with Terraform variables, and returns a backend configuration.
-func LoadConfiguration(dirs []string, chdir string, vars variable.Variables) (map[string]string, error) {
+// renders them with Terraform variables and environment metadata,
+// and returns a backend configuration.
+func LoadConfiguration(dirs []string, chdir string, vars variable.Variables, env *environment.Environment) (map[string]string, error) {
 	filenames, err := findBackendFiles(dirs, chdir)
 	if err != nil {
 		return nil, err
@@ -26,7 +27,7 @@ func LoadConfiguration(dirs []string, chdir string, vars variable.Variables) (ma
 	backend := map[string]string{}
 
 	for _, filename := range filenames {
-		if config, err := parseBackendFile(filename, vars); err != nil {
+		if config, err := parseBackendFile(filename, vars, env); err != nil {
 			return nil, err
 		} else {
 			for name, value := range config {
@@ -64,8 +65,10 @@ func findBackendFiles(dirs []string, chdir string) (filenames []string, err erro
 }
 
 // parseBackendFile parses a *.tfbackend file as HCL into a map of strings.
-// Variables can be used in the same way as *.tf files using the `var` object.
-func parseBackendFile(filename string, vars variable.Variables) (map[string]string, error) {
+// Variables can be used in the same way as *.tf files using the `var` object,
+// and environment metadata can be used with the `environment` object, as in
+// hook conditions. The metadata is also available as `env` in these files.
+func parseBackendFile(filename string, vars variable.Variables, env *environment.Environment) (map[string]string, error) {
 	// Parse the file.
 	p := hclparse.NewParser()
 	file, diags := p.ParseHCLFile(filename)
@@ -75,10 +78,12 @@ func parseBackendFile(filename string, vars variable.Variables) (map[string]stri
 
 	// Decode the file into a map of cty values.
 	values := map[string]cty.Value{}
-	ctx, err := varEvalContext(vars)
+	ctx, err := vars.EvalContext()
 	if err != nil {
 		return nil, fmt.Errorf("creating backend context for %s: %w", filename, err)
 	}
+	ctx.Variables["environment"] = env.Value()
+	ctx.Variables["env"] = env.Value()
 	diags = gohcl.DecodeBody(file.Body, ctx, &values)
 	if diags.HasErrors() {
 		return nil, fmt.Errorf("decoding hcl %s: %s", filename, diags.Error())
@@ -105,24 +110,3 @@ func parseBackendFile(filename string, vars variable.Variables) (map[string]stri
 
 	return strings, nil
 }
-
-// varEvalContext returns an EvalContext with a `var` object containing variables.
-func varEvalContext(vars variable.Variables) (*hcl.EvalContext, error) {
-	values := map[string]cty.Value{}
-	for _, v := range vars {
-		ct, err := gocty.ImpliedType(v.AnyValue)
-		if err != nil {
-			return nil, fmt.Errorf("getting cty type for var.%s (%v): %w", v.Name, v.AnyValue, err)
-		}
-		cv, err := gocty.ToCtyValue(v.AnyValue, ct)
-		if err != nil {
-			return nil, fmt.Errorf("converting to cty type: %w", err)
-		}
-		values[v.Name] = cv
-	}
-	ctx := hcl.EvalContext{}
-	ctx.Variables = map[string]cty.Value{
-		"var": cty.ObjectVal(values),
-	}
-	return &ctx, nil
-}
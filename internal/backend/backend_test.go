@@ -9,6 +9,7 @@ import (
 	"github.com/matryer/is"
 	"github.com/raymondbutcher/ltf"
 	"github.com/raymondbutcher/ltf/internal/arguments"
+	"github.com/raymondbutcher/ltf/internal/environment"
 	"github.com/raymondbutcher/ltf/internal/variable"
 )
 
@@ -34,7 +35,7 @@ func TestParseBackendFile(t *testing.T) {
 
 	// Act
 
-	values, err := parseBackendFile(filename, vars)
+	values, err := parseBackendFile(filename, vars, &environment.Environment{Name: "live", Tier: "production"})
 	is.NoErr(err)
 
 	// Assert
@@ -44,4 +45,6 @@ func TestParseBackendFile(t *testing.T) {
 	is.Equal(values["region"], "eu-west-1")
 	is.Equal(values["extra"], "success")
 	is.Equal(values["encrypted"], "true")
+	is.Equal(values["workspace"], "live")
+	is.Equal(values["tier"], "production")
 }
@@ -0,0 +1,25 @@
+package command
+
+import (
+	"os/exec"
+)
+
+// Command is a custom LTF subcommand defined in the settings file.
+// It runs a script instead of Terraform.
+type Command struct {
+	Name        string `yaml:"-"`
+	Description string `yaml:"description,omitempty"`
+	Script      string `yaml:"script,omitempty"`
+
+	// Disabled removes a command defined in a parent directory's settings file.
+	Disabled bool `yaml:"disabled,omitempty"`
+}
+
+// Cmd returns a command to run the script with Bash. The extra arguments
+// are passed to the script as positional parameters, so "ltf $name a b"
+// makes "$1" and "$2" available to the script as "a" and "b".
+func (c *Command) Cmd(extraArgs []string) *exec.Cmd {
+	args := []string{"-c", c.Script, "ltf " + c.Name}
+	args = append(args, extraArgs...)
+	return exec.Command("bash", args...)
+}
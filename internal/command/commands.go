@@ -0,0 +1,46 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type Commands map[string]*Command
+
+// builtins are the names of LTF's built-in commands,
+// which cannot be used as custom command names.
+var builtins = map[string]bool{
+	"cache":        true,
+	"environments": true,
+	"hooks":        true,
+	"schema":       true,
+	"settings":     true,
+}
+
+// Names returns the command names in alphabetical order.
+func (m Commands) Names() []string {
+	names := []string{}
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate returns an error if any command has a name
+// that could not be used as a subcommand, or that is used by a built-in command.
+func (m Commands) Validate() error {
+	for _, name := range m.Names() {
+		if name == "" || strings.HasPrefix(name, "-") || strings.ContainsAny(name, " \t\n") {
+			return fmt.Errorf("invalid command name %q", name)
+		}
+		if builtins[name] {
+			return fmt.Errorf("invalid command name %q: it is used by a built-in LTF command", name)
+		}
+		if m[name].Script == "" {
+			return fmt.Errorf("command %s has no script", name)
+		}
+	}
+	return nil
+}
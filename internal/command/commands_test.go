@@ -0,0 +1,20 @@
+package command
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestCommandsValidate(t *testing.T) {
+	is := is.New(t)
+
+	is.NoErr(Commands{"bootstrap": &Command{Script: "true"}}.Validate())
+	is.True(Commands{"": &Command{Script: "true"}}.Validate() != nil)           // empty name
+	is.True(Commands{"-bootstrap": &Command{Script: "true"}}.Validate() != nil) // looks like an option
+	is.True(Commands{"boot strap": &Command{Script: "true"}}.Validate() != nil) // contains a space
+	is.True(Commands{"bootstrap": &Command{}}.Validate() != nil)                // needs a script
+	for name := range builtins {
+		is.True(Commands{name: &Command{Script: "true"}}.Validate() != nil) // built-in command name
+	}
+}
@@ -0,0 +1,109 @@
+// Package environment holds metadata about environment directories,
+// such as their name, tier and owner.
+package environment
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Environment holds metadata about an environment directory, declared in
+// the `environment` section of settings files. Settings files in deeper
+// directories override the fields set by settings files in parent directories.
+type Environment struct {
+	// Name is the name of the environment, such as "live-blue".
+	Name string `yaml:"name,omitempty" json:"name"`
+
+	// Tier is the tier of the environment, such as "dev" or "live".
+	Tier string `yaml:"tier,omitempty" json:"tier"`
+
+	// Owner is the team or person responsible for the environment.
+	Owner string `yaml:"owner,omitempty" json:"owner"`
+
+	// Labels are any other metadata about the environment.
+	Labels map[string]string `yaml:"labels,omitempty" json:"labels"`
+
+	// Protected marks environments that need extra care, such as production.
+	// LTF does not use it, but hooks can, for example to require approval.
+	Protected *bool `yaml:"protected,omitempty" json:"protected"`
+}
+
+// Merge updates the environment with the fields that are set in other.
+// Labels are merged, with values from other taking precedence.
+func (e *Environment) Merge(other *Environment) {
+	if other.Name != "" {
+		e.Name = other.Name
+	}
+	if other.Tier != "" {
+		e.Tier = other.Tier
+	}
+	if other.Owner != "" {
+		e.Owner = other.Owner
+	}
+	for key, value := range other.Labels {
+		if e.Labels == nil {
+			e.Labels = map[string]string{}
+		}
+		e.Labels[key] = value
+	}
+	if other.Protected != nil {
+		protected := *other.Protected
+		e.Protected = &protected
+	}
+}
+
+// IsProtected reports whether the environment is protected.
+func (e *Environment) IsProtected() bool {
+	return e != nil && e.Protected != nil && *e.Protected
+}
+
+// labels returns the labels, or an empty map if there are none.
+func (e *Environment) labels() map[string]string {
+	if e == nil || e.Labels == nil {
+		return map[string]string{}
+	}
+	return e.Labels
+}
+
+// Variables returns the environment variables that describe the
+// environment to hooks. Labels are encoded as a JSON object.
+func (e *Environment) Variables() map[string]string {
+	if e == nil {
+		return map[string]string{}
+	}
+	// Encoding a map of strings cannot fail.
+	labels, _ := json.Marshal(e.labels())
+	return map[string]string{
+		"LTF_ENV_NAME":      e.Name,
+		"LTF_ENV_TIER":      e.Tier,
+		"LTF_ENV_OWNER":     e.Owner,
+		"LTF_ENV_LABELS":    string(labels),
+		"LTF_ENV_PROTECTED": fmt.Sprint(e.IsProtected()),
+	}
+}
+
+// Value returns the environment as an HCL object, for use in expressions.
+// A nil environment returns an object with empty values.
+func (e *Environment) Value() cty.Value {
+	name, tier, owner := "", "", ""
+	if e != nil {
+		name, tier, owner = e.Name, e.Tier, e.Owner
+	}
+	labels := map[string]cty.Value{}
+	for key, value := range e.labels() {
+		labels[key] = cty.StringVal(value)
+	}
+	labelsValue := cty.MapValEmpty(cty.String)
+	if len(labels) > 0 {
+		labelsValue = cty.MapVal(labels)
+	}
+	return cty.ObjectVal(map[string]cty.Value{
+		"name":      cty.StringVal(name),
+		"tier":      cty.StringVal(tier),
+		"owner":     cty.StringVal(owner),
+		"labels":    labelsValue,
+		"protected": cty.BoolVal(e.IsProtected()),
+	})
+}
@@ -0,0 +1,60 @@
+package environment
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestMerge(t *testing.T) {
+	is := is.New(t)
+
+	// Arrange
+
+	yes, no := true, false
+	e := &Environment{Tier: "live", Owner: "platform", Labels: map[string]string{"team": "platform"}, Protected: &yes}
+
+	// Act
+
+	e.Merge(&Environment{Name: "live-blue", Labels: map[string]string{"colour": "blue"}})
+	e.Merge(&Environment{Owner: "payments", Protected: &no})
+
+	// Assert
+
+	is.Equal(e.Name, "live-blue")
+	is.Equal(e.Tier, "live")
+	is.Equal(e.Owner, "payments")
+	is.Equal(e.Labels, map[string]string{"colour": "blue", "team": "platform"})
+	is.Equal(e.IsProtected(), false) // protected can be turned off in deeper directories
+	is.Equal(yes, true)              // values are copied
+}
+
+func TestVariables(t *testing.T) {
+	is := is.New(t)
+
+	yes := true
+	e := &Environment{Name: "live-blue", Tier: "live", Labels: map[string]string{"colour": "blue"}, Protected: &yes}
+
+	is.Equal(e.Variables(), map[string]string{
+		"LTF_ENV_NAME":      "live-blue",
+		"LTF_ENV_TIER":      "live",
+		"LTF_ENV_OWNER":     "",
+		"LTF_ENV_LABELS":    `{"colour":"blue"}`,
+		"LTF_ENV_PROTECTED": "true",
+	})
+	is.Equal(len((*Environment)(nil).Variables()), 0)
+}
+
+func TestValue(t *testing.T) {
+	is := is.New(t)
+
+	value := (&Environment{Name: "dev", Labels: map[string]string{"colour": "green"}}).Value()
+	empty := (*Environment)(nil).Value()
+
+	is.Equal(value.GetAttr("name"), cty.StringVal("dev"))
+	is.Equal(value.GetAttr("labels").Index(cty.StringVal("colour")), cty.StringVal("green"))
+	is.Equal(value.GetAttr("protected"), cty.False)
+	is.Equal(empty.GetAttr("name"), cty.StringVal(""))
+	is.Equal(empty.GetAttr("labels").LengthInt(), 0)
+}
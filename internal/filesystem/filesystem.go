@@ -77,7 +77,7 @@ func findDirsWithChdir(cwd string, chdir string) ([]string, error) {
 
 func findDirsWithoutChdir(cwd string) ([]string, error) {
 	// Returns all directories between the current directory
-	// and a parent directory containing Terraform configuration files,
+	// and a parent directory containing Terraform or OpenTofu configuration files,
 	// which will be used as the configuration directory. If no configuration
 	// directory is found, then only the current directory is returned.
 
@@ -93,7 +93,7 @@ func findDirsWithoutChdir(cwd string) ([]string, error) {
 		// Stop if this directory contains configuration files.
 		if files, err = ReadNames(dir); err != nil {
 			return nil, err
-		} else if len(MatchNames(files, "*.tf")) > 0 || len(MatchNames(files, "*.tf.json")) > 0 {
+		} else if IsConfigDir(files) {
 			return dirs, nil
 		}
 
@@ -113,6 +113,19 @@ func findDirsWithoutChdir(cwd string) ([]string, error) {
 	}
 }
 
+// configPatterns match the names of Terraform and OpenTofu configuration files.
+var configPatterns = []string{"*.tf", "*.tf.json", "*.tofu", "*.tofu.json"}
+
+// IsConfigDir reports whether the files include any configuration files.
+func IsConfigDir(files []string) bool {
+	for _, pattern := range configPatterns {
+		if len(MatchNames(files, pattern)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 func MatchNames(files []string, pattern string) []string {
 	matches := []string{}
 	for _, name := range files {
@@ -0,0 +1,94 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/terraform-config-inspect/tfconfig"
+)
+
+// tofuExtensions maps OpenTofu file extensions to the
+// Terraform file extensions that they replace.
+var tofuExtensions = map[string]string{
+	".tofu":      ".tf",
+	".tofu.json": ".tf.json",
+}
+
+// tofuFS is a filesystem that presents *.tofu and *.tofu.json files as
+// *.tf and *.tf.json files, so that tfconfig can read OpenTofu configuration.
+// Like OpenTofu, it ignores a *.tf file if there is a *.tofu file with the
+// same name.
+type tofuFS struct {
+	tfconfig.FS
+
+	// renamed maps the presented paths to the real paths.
+	renamed map[string]string
+}
+
+// LoadModule reads the Terraform or OpenTofu configuration in a directory.
+func LoadModule(dir string) (*tfconfig.Module, tfconfig.Diagnostics) {
+	return tfconfig.LoadModuleFromFilesystem(newTofuFS(), dir)
+}
+
+func newTofuFS() *tofuFS {
+	return &tofuFS{FS: tfconfig.NewOsFs(), renamed: map[string]string{}}
+}
+
+func (fs *tofuFS) Open(name string) (tfconfig.File, error) {
+	if real, ok := fs.renamed[name]; ok {
+		name = real
+	}
+	return fs.FS.Open(name)
+}
+
+func (fs *tofuFS) ReadFile(name string) ([]byte, error) {
+	if real, ok := fs.renamed[name]; ok {
+		name = real
+	}
+	return fs.FS.ReadFile(name)
+}
+
+func (fs *tofuFS) ReadDir(dirname string) ([]os.FileInfo, error) {
+	infos, err := fs.FS.ReadDir(dirname)
+	if err != nil {
+		return nil, err
+	}
+
+	overridden := map[string]bool{}
+	for _, info := range infos {
+		if name, ok := tofuName(info.Name()); ok {
+			overridden[name] = true
+		}
+	}
+
+	result := []os.FileInfo{}
+	for _, info := range infos {
+		if name, ok := tofuName(info.Name()); ok {
+			fs.renamed[filepath.Join(dirname, name)] = filepath.Join(dirname, info.Name())
+			result = append(result, renamedFileInfo{FileInfo: info, name: name})
+		} else if !overridden[info.Name()] {
+			result = append(result, info)
+		}
+	}
+	return result, nil
+}
+
+// tofuName returns the Terraform file name for an OpenTofu file name.
+func tofuName(name string) (string, bool) {
+	for ext, tfExt := range tofuExtensions {
+		if strings.HasSuffix(name, ext) {
+			return strings.TrimSuffix(name, ext) + tfExt, true
+		}
+	}
+	return "", false
+}
+
+type renamedFileInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (fi renamedFileInfo) Name() string {
+	return fi.name
+}
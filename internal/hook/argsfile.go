@@ -0,0 +1,28 @@
+package hook
+
+import (
+	"strings"
+)
+
+// formatArgsFile returns the content of $LTF_ARGS_FILE for the given
+// command line arguments, with one argument per line.
+func formatArgsFile(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return strings.Join(args, "\n") + "\n"
+}
+
+// parseArgsFile returns the command line arguments from the content of
+// $LTF_ARGS_FILE. Each line is one argument, and blank lines are skipped.
+func parseArgsFile(content []byte) []string {
+	args := []string{}
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		args = append(args, line)
+	}
+	return args
+}
@@ -0,0 +1,25 @@
+package hook
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestArgsFile(t *testing.T) {
+	is := is.New(t)
+
+	// Arrange
+
+	args := []string{"plan", "-var=x=a b", "-lock=false"}
+
+	// Act
+
+	content := formatArgsFile(args)
+	parsed := parseArgsFile([]byte(content + "\n-parallelism=5\r\n"))
+
+	// Assert
+
+	is.Equal(content, "plan\n-var=x=a b\n-lock=false\n")
+	is.Equal(parsed, []string{"plan", "-var=x=a b", "-lock=false", "-parallelism=5"})
+}
@@ -0,0 +1,203 @@
+package hook
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/raymondbutcher/ltf"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// now is replaced in tests to control cache expiry.
+var now = time.Now
+
+// Cache configures a hook to reuse its results instead of running every time.
+type Cache struct {
+	// TTL is how long the results are reused for.
+	TTL time.Duration `yaml:"ttl,omitempty"`
+
+	// Key is an HCL template, such as "${env.AWS_PROFILE}", which is evaluated
+	// in the same way as hook conditions. Results are cached separately for
+	// each key, so a change to the key makes the hook run again.
+	Key string `yaml:"key,omitempty"`
+}
+
+// Validate returns an error if the cache settings are invalid.
+func (c *Cache) Validate() error {
+	if c.TTL <= 0 {
+		return fmt.Errorf("cache ttl must be greater than 0")
+	}
+	if _, err := parseCacheKey(c.Key); err != nil {
+		return err
+	}
+	return nil
+}
+
+// cacheEntry is the content of a cache file.
+type cacheEntry struct {
+	Expires time.Time              `json:"expires"`
+	Env     map[string]*string     `json:"env"`
+	Vars    map[string]interface{} `json:"vars,omitempty"`
+
+	// Args holds the command line arguments from $LTF_ARGS_FILE,
+	// and ArgsFrom holds the arguments that the hook started with.
+	// The arguments are only reused when the hook starts with the same ones.
+	Args     []string `json:"args,omitempty"`
+	ArgsFrom []string `json:"args_from,omitempty"`
+}
+
+// CacheDir returns the directory used for cached hook results.
+func CacheDir(dataDir string) string {
+	return filepath.Join(dataDir, "ltf", "cache")
+}
+
+// ClearCache removes all cached hook results from the data directory.
+func ClearCache(dataDir string) error {
+	return os.RemoveAll(CacheDir(dataDir))
+}
+
+// cacheFile returns the path of the file used to cache the hook's results
+// for the current event, or an empty string if there is no data directory.
+// The file name is a hash of the whole hook definition and the evaluated key.
+func (h *Hook) cacheFile(event *Event, env ltf.Environ) (string, error) {
+	dataDir := event.DataDir(env)
+	if dataDir == "" {
+		return "", nil
+	}
+
+	key, err := evalCacheKey(h.Cache.Key, event, env)
+	if err != nil {
+		return "", err
+	}
+
+	// Any change to the hook definition makes it run again,
+	// so the whole definition is included in the hash.
+	definition, err := json.Marshal(h)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	for _, part := range []string{string(definition), key} {
+		fmt.Fprintf(hash, "%d:%s;", len(part), part)
+	}
+	return filepath.Join(CacheDir(dataDir), hex.EncodeToString(hash.Sum(nil))+".json"), nil
+}
+
+// readCache returns the cached result from the file, applied to the environment.
+// It returns false if the file does not exist, cannot be read, or has expired,
+// or if the hook changed the command line arguments when it started with
+// different ones.
+func readCache(file string, env ltf.Environ, args []string) (*Result, bool) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, false
+	}
+	entry := cacheEntry{}
+	if err := json.Unmarshal(content, &entry); err != nil {
+		return nil, false
+	}
+	if !now().Before(entry.Expires) {
+		return nil, false
+	}
+	if entry.Args != nil && !reflect.DeepEqual(entry.ArgsFrom, args) {
+		return nil, false
+	}
+
+	result := &Result{Env: env, Vars: entry.Vars, Args: entry.Args}
+	for name, value := range entry.Env {
+		if value == nil {
+			result.Env = result.Env.UnsetValue(name)
+		} else {
+			result.Env = result.Env.SetValue(name, *value)
+		}
+	}
+	return result, true
+}
+
+// writeCache saves the changes that a hook made to the environment,
+// variables and command line arguments. The directory and file are only
+// accessible by the current user, because hooks often export credentials.
+func writeCache(file string, ttl time.Duration, env ltf.Environ, args []string, result *Result) error {
+	entry := cacheEntry{
+		Expires: now().Add(ttl),
+		Env:     envChanges(env, result.Env),
+		Vars:    result.Vars,
+	}
+	if result.Args != nil {
+		entry.Args = result.Args
+		entry.ArgsFrom = args
+	}
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(file)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if err := os.Chmod(dir, 0700); err != nil {
+		return err
+	}
+
+	// Write to a temporary file and rename it, so that
+	// concurrent runs never read a partially written file.
+	f, err := ioutil.TempFile(dir, ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), file)
+}
+
+// parseCacheKey parses a cache key as an HCL template.
+func parseCacheKey(key string) (hclsyntax.Expression, error) {
+	expr, diags := hclsyntax.ParseTemplate([]byte(key), "key", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("parsing cache key: %s", diags.Error())
+	}
+	return expr, nil
+}
+
+// evalCacheKey evaluates a cache key with the same objects as hook conditions.
+func evalCacheKey(key string, event *Event, env ltf.Environ) (string, error) {
+	if strings.TrimSpace(key) == "" {
+		return "", nil
+	}
+
+	expr, err := parseCacheKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, err := event.EvalContext(env)
+	if err != nil {
+		return "", err
+	}
+
+	value, diags := expr.Value(ctx)
+	if diags.HasErrors() {
+		return "", fmt.Errorf("evaluating cache key: %s", diags.Error())
+	}
+	if value.IsNull() || !value.IsKnown() || value.Type() != cty.String {
+		return "", fmt.Errorf("evaluating cache key: result must be a string")
+	}
+	return value.AsString(), nil
+}
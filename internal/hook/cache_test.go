@@ -0,0 +1,107 @@
+package hook
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/raymondbutcher/ltf"
+	"github.com/raymondbutcher/ltf/internal/arguments"
+)
+
+func TestRunHookCache(t *testing.T) {
+	is := is.New(t)
+
+	// Arrange
+
+	current := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	tempDir, err := os.MkdirTemp("", "ltf-test-")
+	is.NoErr(err)
+	defer os.RemoveAll(tempDir)
+	counter := filepath.Join(tempDir, "counter")
+
+	args, err := arguments.New([]string{"ltf", "plan"}, ltf.NewEnviron())
+	is.NoErr(err)
+	event := &Event{When: "before", Args: args, Cwd: tempDir, Chdir: tempDir}
+
+	h := &Hook{
+		Name:   "login",
+		Shell:  "sh",
+		Script: `echo x >> "$COUNTER"; echo "TOKEN=$PROFILE-$(wc -l < "$COUNTER" | tr -d ' ')" >> "$LTF_ENV_FILE"`,
+		Cache:  &Cache{TTL: time.Hour, Key: "${env.PROFILE}"},
+	}
+	env := ltf.NewEnviron("COUNTER="+counter, "PROFILE=dev")
+	run := func(env ltf.Environ) string {
+		result, err := runHook(h, event, env)
+		is.NoErr(err)
+		return result.Env.GetValue("TOKEN")
+	}
+
+	// Act and Assert
+
+	is.Equal(run(env), "dev-1")
+	is.Equal(run(env), "dev-1") // cached
+
+	is.Equal(run(env.SetValue("PROFILE", "live")), "live-2") // different key
+	is.Equal(run(env), "dev-1")                              // still cached
+
+	current = current.Add(2 * time.Hour)
+	is.Equal(run(env), "dev-3") // expired
+
+	cacheDir := CacheDir(filepath.Join(tempDir, ".terraform"))
+	info, err := os.Stat(cacheDir)
+	is.NoErr(err)
+	is.Equal(info.Mode().Perm(), os.FileMode(0700))
+	files, err := ioutil.ReadDir(cacheDir)
+	is.NoErr(err)
+	is.Equal(len(files), 2)
+	for _, file := range files {
+		is.Equal(file.Mode().Perm(), os.FileMode(0600))
+	}
+
+	is.NoErr(ClearCache(filepath.Join(tempDir, ".terraform")))
+	is.Equal(run(env), "dev-4") // cleared
+
+	h.Env = map[string]string{"REGION": "eu-west-1"}
+	is.Equal(run(env), "dev-5") // hook definition changed
+	is.Equal(run(env), "dev-5") // cached
+}
+
+func TestRunHookCacheArgs(t *testing.T) {
+	is := is.New(t)
+
+	// Arrange
+
+	tempDir, err := os.MkdirTemp("", "ltf-test-")
+	is.NoErr(err)
+	defer os.RemoveAll(tempDir)
+	counter := filepath.Join(tempDir, "counter")
+
+	h := &Hook{
+		Name:   "lock timeout",
+		Shell:  "sh",
+		Script: `echo x >> "$COUNTER"; echo "-lock-timeout=$(wc -l < "$COUNTER" | tr -d ' ')m" >> "$LTF_ARGS_FILE"`,
+		Cache:  &Cache{TTL: time.Hour},
+	}
+	env := ltf.NewEnviron("COUNTER=" + counter)
+	run := func(cmdArgs ...string) []string {
+		args, err := arguments.New(append([]string{"ltf"}, cmdArgs...), ltf.NewEnviron())
+		is.NoErr(err)
+		event := &Event{When: "before", Args: args, Cwd: tempDir, Chdir: tempDir}
+		result, err := runHook(h, event, env)
+		is.NoErr(err)
+		return result.Args
+	}
+
+	// Act and Assert
+
+	is.Equal(run("plan"), []string{"plan", "-lock-timeout=1m"})
+	is.Equal(run("plan"), []string{"plan", "-lock-timeout=1m"})                                 // cached
+	is.Equal(run("plan", "-input=false"), []string{"plan", "-input=false", "-lock-timeout=2m"}) // different arguments
+}
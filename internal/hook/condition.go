@@ -0,0 +1,82 @@
+package hook
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/tryfunc"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/raymondbutcher/ltf"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// parseCondition parses a hook condition as an HCL expression.
+func parseCondition(condition string) (hclsyntax.Expression, error) {
+	expr, diags := hclsyntax.ParseExpression([]byte(condition), "if", hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("parsing condition: %s", diags.Error())
+	}
+	return expr, nil
+}
+
+// evalCondition evaluates a hook condition with `var`, `env` and `path` objects.
+func evalCondition(condition string, event *Event, env ltf.Environ) (bool, error) {
+	expr, err := parseCondition(condition)
+	if err != nil {
+		return false, err
+	}
+
+	ctx, err := event.EvalContext(env)
+	if err != nil {
+		return false, err
+	}
+
+	value, diags := expr.Value(ctx)
+	if diags.HasErrors() {
+		return false, fmt.Errorf("evaluating condition: %s", diags.Error())
+	}
+	if value.Type() != cty.Bool {
+		return false, fmt.Errorf("evaluating condition: result must be a bool, not %s", value.Type().FriendlyName())
+	}
+	if value.IsNull() || !value.IsKnown() {
+		return false, nil
+	}
+	return value.True(), nil
+}
+
+// EvalContext returns an EvalContext for hook conditions and ltf.hcl files.
+// It has the same `var` and `environment` objects as *.tfbackend files,
+// along with `path`, and `env` for environment variables. In *.tfbackend
+// files, `env` is the environment metadata instead.
+func (e *Event) EvalContext(env ltf.Environ) (*hcl.EvalContext, error) {
+	ctx, err := e.Vars.EvalContext()
+	if err != nil {
+		return nil, err
+	}
+
+	envValues := map[string]cty.Value{}
+	for _, item := range env {
+		s := strings.SplitN(item, "=", 2)
+		if len(s) == 2 {
+			envValues[s[0]] = cty.StringVal(s[1])
+		}
+	}
+	ctx.Variables["env"] = cty.ObjectVal(envValues)
+
+	ctx.Variables["path"] = cty.ObjectVal(map[string]cty.Value{
+		"cwd":  cty.StringVal(e.Cwd),
+		"env":  cty.StringVal(e.EnvDir()),
+		"root": cty.StringVal(e.Chdir),
+	})
+
+	ctx.Variables["environment"] = e.Environment.Value()
+
+	ctx.Functions = map[string]function.Function{
+		"can": tryfunc.CanFunc,
+		"try": tryfunc.TryFunc,
+	}
+
+	return ctx, nil
+}
@@ -0,0 +1,77 @@
+package hook
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/raymondbutcher/ltf"
+	"github.com/raymondbutcher/ltf/internal/variable"
+)
+
+func TestEvalCondition(t *testing.T) {
+	vars := variable.Variables{}
+	_, err := vars.SetValue("env", "live", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := &Event{
+		When:  "before",
+		Vars:  vars,
+		Cwd:   "/project/live/blue",
+		Chdir: "/project",
+	}
+	env := ltf.NewEnviron("CI=true")
+
+	tests := []struct {
+		condition string
+		want      bool
+	}{
+		{`var.env == "live"`, true},
+		{`var.env == "dev"`, false},
+		{`env.CI == "true"`, true},
+		{`try(env.MISSING, "") == ""`, true},
+		{`try(env.CI, "") != "true"`, false},
+		{`can(var.missing)`, false},
+		{`path.env == "live/blue"`, true},
+		{`path.root == "/project"`, true},
+		{`var.env == "live" && path.env != "live/green"`, true},
+	}
+	for _, test := range tests {
+		t.Run(test.condition, func(t *testing.T) {
+			is := is.New(t)
+
+			// Act
+
+			got, err := evalCondition(test.condition, event, env)
+
+			// Assert
+
+			is.NoErr(err)
+			is.Equal(got, test.want)
+		})
+	}
+
+	t.Run("not a bool", func(t *testing.T) {
+		is := is.New(t)
+
+		_, err := evalCondition(`var.env`, event, env)
+
+		is.True(err != nil)
+	})
+
+	t.Run("unknown variable", func(t *testing.T) {
+		is := is.New(t)
+
+		_, err := evalCondition(`var.missing == "x"`, event, env)
+
+		is.True(err != nil)
+	})
+
+	t.Run("unset environment variable", func(t *testing.T) {
+		is := is.New(t)
+
+		_, err := evalCondition(`env.MISSING != "true"`, event, env)
+
+		is.True(err != nil) // needs try(env.MISSING, "")
+	})
+}
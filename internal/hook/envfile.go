@@ -0,0 +1,63 @@
+package hook
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/raymondbutcher/ltf"
+)
+
+// parseEnvFile parses the contents of a file written by a hook to $LTF_ENV_FILE,
+// and returns the environment with the variables from the file added to it.
+//
+// Each line in the file sets one variable using NAME=value. Values spanning
+// multiple lines can be set using a heredoc-style delimiter:
+//
+//	NAME<<EOF
+//	first line
+//	second line
+//	EOF
+//
+// Empty lines and lines starting with # are ignored.
+func parseEnvFile(content []byte, env ltf.Environ) (ltf.Environ, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if i := strings.Index(line, "<<"); i > 0 && !strings.Contains(line[:i], "=") {
+			name := line[:i]
+			delimiter := line[i+2:]
+			if delimiter == "" {
+				return nil, fmt.Errorf("LTF_ENV_FILE line %d: missing delimiter", lineNumber)
+			}
+			lines := []string{}
+			found := false
+			for scanner.Scan() {
+				lineNumber++
+				if scanner.Text() == delimiter {
+					found = true
+					break
+				}
+				lines = append(lines, scanner.Text())
+			}
+			if !found {
+				return nil, fmt.Errorf("LTF_ENV_FILE: missing delimiter %s for %s", delimiter, name)
+			}
+			env = env.SetValue(name, strings.Join(lines, "\n"))
+		} else if s := strings.SplitN(line, "=", 2); len(s) == 2 && s[0] != "" {
+			env = env.SetValue(s[0], s[1])
+		} else {
+			return nil, fmt.Errorf("LTF_ENV_FILE line %d: expected NAME=value", lineNumber)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("LTF_ENV_FILE: %w", err)
+	}
+	return env, nil
+}
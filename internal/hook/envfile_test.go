@@ -0,0 +1,48 @@
+package hook
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/raymondbutcher/ltf"
+)
+
+func TestParseEnvFile(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		is := is.New(t)
+
+		// Arrange
+
+		content := []byte("# comment\nONE=1\n\nTWO=a=b\nMULTI<<EOF\nfirst\nsecond\nEOF\nEMPTY=\n")
+		env := ltf.NewEnviron("ONE=old", "KEEP=yes")
+
+		// Act
+
+		env, err := parseEnvFile(content, env)
+
+		// Assert
+
+		is.NoErr(err)
+		is.Equal(env.GetValue("ONE"), "1")
+		is.Equal(env.GetValue("TWO"), "a=b")
+		is.Equal(env.GetValue("MULTI"), "first\nsecond")
+		is.Equal(env.GetValue("EMPTY"), "")
+		is.Equal(env.GetValue("KEEP"), "yes")
+	})
+
+	t.Run("missing delimiter", func(t *testing.T) {
+		is := is.New(t)
+
+		_, err := parseEnvFile([]byte("MULTI<<EOF\nfirst\n"), ltf.NewEnviron())
+
+		is.True(err != nil)
+	})
+
+	t.Run("invalid line", func(t *testing.T) {
+		is := is.New(t)
+
+		_, err := parseEnvFile([]byte("not a variable\n"), ltf.NewEnviron())
+
+		is.True(err != nil)
+	})
+}
@@ -0,0 +1,143 @@
+package hook
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/raymondbutcher/ltf"
+	"github.com/raymondbutcher/ltf/internal/arguments"
+	"github.com/raymondbutcher/ltf/internal/environment"
+	"github.com/raymondbutcher/ltf/internal/variable"
+)
+
+// eventVariables are the names of environment variables that LTF sets
+// when running hooks. They are not passed on to Terraform.
+var eventVariables = []string{
+	"LTF_SUBCOMMAND",
+	"LTF_ARGS",
+	"LTF_CONFIG_DIR",
+	"LTF_ENV_DIR",
+	"LTF_DATA_DIR",
+	"LTF_HOOK_NAME",
+	"LTF_EXIT_CODE",
+	"LTF_DURATION_MS",
+	"LTF_ERROR",
+	"LTF_ENV_NAME",
+	"LTF_ENV_TIER",
+	"LTF_ENV_OWNER",
+	"LTF_ENV_LABELS",
+	"LTF_ENV_PROTECTED",
+}
+
+// Event contains information about the current LTF run,
+// used to decide which hooks to run and how to run them.
+type Event struct {
+	// When is "before", "after", "failed", "finally", "on_error" or "output".
+	When string
+
+	// Args holds the arguments that LTF was run with.
+	Args *arguments.Arguments
+
+	// Vars holds the Terraform variables loaded by LTF.
+	Vars variable.Variables
+
+	// Cwd is the absolute path of the current directory,
+	// which is the environment directory.
+	Cwd string
+
+	// Chdir is the absolute path of the configuration directory.
+	Chdir string
+
+	// ExitCode is the exit code of the Terraform command,
+	// for "after" and "failed" events, or the exit code of LTF,
+	// for "finally" and "on_error" events.
+	ExitCode int
+
+	// Duration is how long the Terraform command ran for,
+	// for "after" and "failed" events.
+	Duration time.Duration
+
+	// Error is the error message from LTF, for "finally"
+	// and "on_error" events when LTF has failed.
+	Error string
+
+	// Environment holds metadata about the environment directory
+	// from the settings files, or nil if there is none.
+	Environment *environment.Environment
+}
+
+// With returns a copy of the event for a different point in time.
+func (e Event) With(when string) *Event {
+	e.When = when
+	return &e
+}
+
+// EnvDir returns the path of the current directory relative to the configuration directory.
+func (e *Event) EnvDir() string {
+	if e.Cwd == "" || e.Chdir == "" {
+		return ""
+	}
+	rel, err := filepath.Rel(e.Chdir, e.Cwd)
+	if err != nil {
+		return ""
+	}
+	return rel
+}
+
+// DataDir returns the absolute path of the Terraform data directory,
+// or an empty string if there is no configuration directory.
+func (e *Event) DataDir(env ltf.Environ) string {
+	if e.Chdir == "" {
+		return ""
+	}
+	dataDir := env.GetValue("TF_DATA_DIR")
+	if dataDir == "" {
+		dataDir = ".terraform"
+	}
+	if !filepath.IsAbs(dataDir) {
+		dataDir = filepath.Join(e.Chdir, dataDir)
+	}
+	return dataDir
+}
+
+// environ returns the environment for running a hook,
+// with variables describing the event added to it.
+func (e *Event) environ(hookName string, env ltf.Environ) ltf.Environ {
+	env = env.SetValue("LTF_HOOK_NAME", hookName)
+	env = env.SetValue("LTF_SUBCOMMAND", e.Args.Subcommand)
+	env = env.SetValue("LTF_ARGS", arguments.Quote(e.Args.Args[1:]))
+	env = env.SetValue("LTF_CONFIG_DIR", e.Chdir)
+	env = env.SetValue("LTF_ENV_DIR", e.EnvDir())
+
+	env = env.SetValue("LTF_DATA_DIR", e.DataDir(env))
+
+	if e.When == "after" || e.When == "failed" || e.When == "finally" || e.When == "on_error" {
+		env = env.SetValue("LTF_EXIT_CODE", fmt.Sprint(e.ExitCode))
+		env = env.SetValue("LTF_DURATION_MS", fmt.Sprint(e.Duration.Milliseconds()))
+	}
+	if e.Error != "" {
+		env = env.SetValue("LTF_ERROR", e.Error)
+	}
+	for name, value := range e.Environment.Variables() {
+		env = env.SetValue(name, value)
+	}
+
+	return env
+}
+
+// restoreEnviron returns the modified environment with any event variables
+// set back to their values from the original environment, so that they
+// are not passed on to Terraform.
+func restoreEnviron(modified ltf.Environ, original ltf.Environ) ltf.Environ {
+	for _, name := range eventVariables {
+		modified = modified.UnsetValue(name)
+		for _, item := range original {
+			if strings.HasPrefix(item, name+"=") {
+				modified = modified.SetValue(name, item[len(name)+1:])
+			}
+		}
+	}
+	return modified
+}
@@ -0,0 +1,50 @@
+package hook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/raymondbutcher/ltf"
+	"github.com/raymondbutcher/ltf/internal/arguments"
+)
+
+func TestEventEnviron(t *testing.T) {
+	is := is.New(t)
+
+	// Arrange
+
+	args, err := arguments.New([]string{"ltf", "plan", "-var", "x=a b"}, ltf.NewEnviron())
+	is.NoErr(err)
+	event := Event{
+		Args:     args,
+		Cwd:      "/project/live/blue",
+		Chdir:    "/project",
+		ExitCode: 2,
+		Duration: 1500 * time.Millisecond,
+	}
+	original := ltf.NewEnviron("TF_DATA_DIR=live/blue/.terraform", "LTF_EXIT_CODE=kept")
+
+	// Act
+
+	before := event.With("before").environ("my hook", original)
+	failed := event.With("failed").environ("my hook", original)
+	restored := restoreEnviron(failed.SetValue("NEW", "1"), original)
+
+	// Assert
+
+	is.Equal(before.GetValue("LTF_HOOK_NAME"), "my hook")
+	is.Equal(before.GetValue("LTF_SUBCOMMAND"), "plan")
+	is.Equal(before.GetValue("LTF_ARGS"), "plan -var 'x=a b'")
+	is.Equal(before.GetValue("LTF_CONFIG_DIR"), "/project")
+	is.Equal(before.GetValue("LTF_ENV_DIR"), "live/blue")
+	is.Equal(before.GetValue("LTF_DATA_DIR"), "/project/live/blue/.terraform")
+	is.Equal(before.GetValue("LTF_EXIT_CODE"), "kept") // only set for after and failed hooks
+
+	is.Equal(failed.GetValue("LTF_EXIT_CODE"), "2")
+	is.Equal(failed.GetValue("LTF_DURATION_MS"), "1500")
+
+	is.Equal(restored.GetValue("NEW"), "1")
+	is.Equal(restored.GetValue("LTF_HOOK_NAME"), "")
+	is.Equal(restored.GetValue("LTF_EXIT_CODE"), "kept")
+}
@@ -0,0 +1,133 @@
+package hook
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/raymondbutcher/ltf"
+)
+
+// StartFilters starts the output filter hooks matching the event. Each
+// filter runs once for each of its streams, reading Terraform's output
+// from stdin and writing the transformed output to stdout. Multiple filters
+// are chained together in the order that hooks run.
+//
+// It returns the writers that Terraform should use for its output,
+// and a function to call after Terraform has finished, which waits for
+// the filters to finish writing to the original stdout and stderr.
+func (m Hooks) StartFilters(event *Event, env ltf.Environ, stdout io.Writer, stderr io.Writer) (filteredStdout io.Writer, filteredStderr io.Writer, wait func() error, err error) {
+	sorted, err := m.Sorted()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	filters := map[string][]*Hook{}
+	for _, h := range sorted {
+		if !h.Match("output", event.Args) {
+			continue
+		}
+		hookEnv := event.environ(h.Name, env)
+		if ok, err := h.Check(event, hookEnv); err != nil {
+			return nil, nil, nil, err
+		} else if !ok {
+			continue
+		}
+		for _, stream := range h.streams() {
+			filters[stream] = append(filters[stream], h)
+		}
+	}
+
+	started := []*exec.Cmd{}
+	names := []string{}
+	pipes := []*os.File{}
+
+	// Closing pipes after Terraform has finished lets the filters
+	// see the end of their input, so they can finish and exit.
+	wait = func() error {
+		for _, w := range pipes {
+			w.Close()
+		}
+		var firstErr error
+		for i, cmd := range started {
+			if err := cmd.Wait(); err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("output filter %s: %w", names[i], err)
+			}
+		}
+		return firstErr
+	}
+
+	chain := func(stream string, out io.Writer) (io.Writer, error) {
+		hooks := filters[stream]
+		w := out
+		// Start from the last filter so that each filter can write to the next one.
+		for i := len(hooks) - 1; i >= 0; i-- {
+			h := hooks[i]
+			r, pw, err := os.Pipe()
+			if err != nil {
+				return nil, err
+			}
+			cmd := h.filterCmd(event.environ(h.Name, h.inputEnv(env)).SetValue("LTF_STREAM", stream))
+			cmd.Stdin = r
+			cmd.Stdout = w
+			cmd.Stderr = os.Stderr
+			if err := cmd.Start(); err != nil {
+				r.Close()
+				pw.Close()
+				// Close the input of the filter started before this one,
+				// so that it can finish and exit.
+				if f, ok := w.(*os.File); ok && w != out {
+					f.Close()
+				}
+				return nil, fmt.Errorf("output filter %s: %w", h.Name, err)
+			}
+			r.Close()
+			if f, ok := w.(*os.File); ok && f != out {
+				f.Close()
+			}
+			started = append(started, cmd)
+			names = append(names, h.Name)
+			w = pw
+		}
+		if f, ok := w.(*os.File); ok && w != out {
+			pipes = append(pipes, f)
+		}
+		return w, nil
+	}
+
+	if filteredStdout, err = chain("stdout", stdout); err != nil {
+		_ = wait()
+		return nil, nil, nil, err
+	}
+	if filteredStderr, err = chain("stderr", stderr); err != nil {
+		_ = wait()
+		return nil, nil, nil, err
+	}
+
+	return filteredStdout, filteredStderr, wait, nil
+}
+
+// filterCmd returns a command to run the hook as an output filter.
+// Unlike other hooks, it does not use the wrapper script,
+// so its stdout is used for the filtered output.
+func (h *Hook) filterCmd(env ltf.Environ) *exec.Cmd {
+	var cmd *exec.Cmd
+	if len(h.Command) > 0 {
+		cmd = exec.Command(h.Command[0], h.Command[1:]...)
+	} else if h.Shell != "" {
+		cmd = exec.Command(h.Shell, "-c", h.Script)
+	} else {
+		cmd = exec.Command("bash", "-c", h.Script)
+	}
+	cmd.Env = env
+	return cmd
+}
+
+// streams returns the output streams that the hook filters.
+func (h *Hook) streams() []string {
+	if len(h.Streams) > 0 {
+		return h.Streams
+	}
+	return []string{"stdout", "stderr"}
+}
@@ -0,0 +1,113 @@
+package hook
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/raymondbutcher/ltf"
+	"github.com/raymondbutcher/ltf/internal/arguments"
+)
+
+func TestStartFilters(t *testing.T) {
+	is := is.New(t)
+
+	// Arrange
+
+	hooks := Hooks{
+		"a prefix": &Hook{
+			Name:   "a prefix",
+			Output: []string{"terraform plan"},
+			Script: `while IFS= read -r line; do echo "[$LTF_STREAM] $line"; done`,
+		},
+		"b upper": &Hook{
+			Name:    "b upper",
+			Output:  []string{"terraform plan"},
+			Streams: []string{"stdout"},
+			Command: []string{"tr", "a-z", "A-Z"},
+		},
+		"c other command": &Hook{
+			Name:   "c other command",
+			Output: []string{"terraform apply"},
+			Script: "exit 1",
+		},
+	}
+	args, err := arguments.New([]string{"ltf", "plan"}, ltf.NewEnviron())
+	is.NoErr(err)
+	event := &Event{When: "output", Args: args}
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	// Act
+
+	filteredStdout, filteredStderr, wait, err := hooks.StartFilters(event, ltf.NewEnviron("PATH=/usr/bin:/bin"), stdout, stderr)
+	is.NoErr(err)
+	fmt.Fprintln(filteredStdout, "one")
+	fmt.Fprintln(filteredStdout, "two")
+	fmt.Fprintln(filteredStderr, "three")
+	err = wait()
+
+	// Assert
+
+	is.NoErr(err)
+	is.Equal(stdout.String(), "[STDOUT] ONE\n[STDOUT] TWO\n")
+	is.Equal(stderr.String(), "[stderr] three\n")
+}
+
+func TestStartFiltersWithoutFilters(t *testing.T) {
+	is := is.New(t)
+
+	args, err := arguments.New([]string{"ltf", "plan"}, ltf.NewEnviron())
+	is.NoErr(err)
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	filteredStdout, filteredStderr, wait, err := Hooks{}.StartFilters(&Event{Args: args}, ltf.NewEnviron(), stdout, stderr)
+
+	is.NoErr(err)
+	is.Equal(filteredStdout, stdout) // stdout should be used directly
+	is.Equal(filteredStderr, stderr) // stderr should be used directly
+	is.NoErr(wait())
+}
+
+func TestStartFiltersStartError(t *testing.T) {
+	is := is.New(t)
+
+	// Arrange
+
+	hooks := Hooks{
+		"a missing shell": &Hook{
+			Name:   "a missing shell",
+			Output: []string{"terraform plan"},
+			Shell:  "ltf-test-missing-shell",
+			Script: "cat",
+		},
+		"b cat": &Hook{
+			Name:    "b cat",
+			Output:  []string{"terraform plan"},
+			Command: []string{"cat"},
+		},
+	}
+	args, err := arguments.New([]string{"ltf", "plan"}, ltf.NewEnviron())
+	is.NoErr(err)
+	event := &Event{When: "output", Args: args}
+
+	// Act
+
+	done := make(chan error, 1)
+	go func() {
+		_, _, _, err := hooks.StartFilters(event, ltf.NewEnviron("PATH=/usr/bin:/bin"), &bytes.Buffer{}, &bytes.Buffer{})
+		done <- err
+	}()
+
+	// Assert
+
+	select {
+	case err := <-done:
+		is.True(err != nil) // the missing shell should cause an error
+	case <-time.After(10 * time.Second):
+		t.Fatal("StartFilters did not return after a filter failed to start")
+	}
+}
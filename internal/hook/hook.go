@@ -7,9 +7,15 @@ import (
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/raymondbutcher/ltf"
 	"github.com/raymondbutcher/ltf/internal/arguments"
+	"github.com/raymondbutcher/ltf/internal/process"
+	"github.com/raymondbutcher/ltf/internal/variable"
 )
 
 var scriptPreamble = fmt.Sprintf(`#!/bin/bash
@@ -24,71 +30,387 @@ __ltf_env_to_json () {
   exit $code
 }
 trap __ltf_env_to_json EXIT
+ltf_skip_terraform () {
+  export LTF_SKIP_TERRAFORM="${1:-0}"
+  exit 0
+}
 `, os.Args[0])
 
+// SkipError is returned when a hook has requested that Terraform should not run.
+// LTF should exit with ExitStatus without running Terraform or any further hooks.
+type SkipError struct {
+	Hook       string
+	ExitStatus int
+}
+
+func (e *SkipError) Error() string {
+	return fmt.Sprintf("hook %s skipped terraform with exit status %d", e.Hook, e.ExitStatus)
+}
+
+// Events are the points in time when hooks can run, in the order that they happen.
+var Events = []string{"before", "output", "after", "failed", "on_error", "finally"}
+
 type Hook struct {
-	Name   string
-	Before []string `yaml:"before"`
-	After  []string `yaml:"after"`
-	Failed []string `yaml:"failed"`
-	Script string   `yaml:"script"`
+	Name      string   `yaml:"-"`
+	Before    []string `yaml:"before,omitempty"`
+	After     []string `yaml:"after,omitempty"`
+	Failed    []string `yaml:"failed,omitempty"`
+	Finally   []string `yaml:"finally,omitempty"`
+	OnError   []string `yaml:"on_error,omitempty"`
+	Output    []string `yaml:"output,omitempty"`
+	Streams   []string `yaml:"streams,omitempty"`
+	Script    string   `yaml:"script,omitempty"`
+	Shell     string   `yaml:"shell,omitempty"`
+	Command   []string `yaml:"command,omitempty"`
+	DependsOn []string `yaml:"depends_on,omitempty"`
+	Group     string   `yaml:"group,omitempty"`
+	If        string   `yaml:"if,omitempty"`
+
+	// Disabled removes a hook defined in a parent directory's settings file.
+	Disabled bool `yaml:"disabled,omitempty"`
+
+	// Timeout is the maximum time that the script can run for.
+	// The script is killed if it runs for longer, along with
+	// any processes that it started.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+
+	// Retry configures the hook to run again if it fails.
+	Retry *Retry `yaml:"retry,omitempty"`
+
+	// Cache configures the hook to reuse its results instead of running every time.
+	Cache *Cache `yaml:"cache,omitempty"`
+
+	// SecretEnv lists environment variables set by the hook that contain secrets.
+	// Their values are redacted from LTF's output, and TF_VAR_name variables
+	// are treated as sensitive Terraform variables.
+	SecretEnv []string `yaml:"secret_env,omitempty"`
+
+	// Env sets environment variables for the hook. They are inputs for the hook,
+	// and they are not passed on to other hooks or Terraform unless the hook
+	// changes them.
+	Env map[string]string `yaml:"env,omitempty"`
+
+	// InheritEnv can be set to false to run the hook with only PATH, HOME,
+	// LTF's variables and the variables in Env, instead of the full environment.
+	InheritEnv *bool `yaml:"inherit_env,omitempty"`
+
+	// Export lists the environment variables that the hook can set or unset
+	// for other hooks and Terraform. Names can use wildcards, e.g. TF_VAR_*.
+	// If empty, all changes made by the hook are passed on.
+	Export []string `yaml:"export,omitempty"`
 }
 
+// inheritedVariables are passed to hooks with `inherit_env: false`.
+var inheritedVariables = []string{"PATH", "HOME"}
+
 // Match reports whether the hook matches the given event and command combination.
+// The command must match at least one of the hook's patterns for the event,
+// and must not match any negated patterns starting with "!".
 func (h *Hook) Match(when string, args *arguments.Arguments) bool {
-	hookCmds := []string{}
+	matched := false
+	for _, pattern := range h.Patterns(when) {
+		negated := strings.HasPrefix(pattern, "!")
+		if negated {
+			pattern = strings.TrimSpace(pattern[1:])
+		}
+		// Patterns are checked by Validate when loading settings,
+		// so errors from invalid patterns can be ignored here.
+		if ok, _ := matchCommand(pattern, args); ok {
+			if negated {
+				return false
+			}
+			matched = true
+		}
+	}
+	return matched
+}
+
+// Validate returns an error if the hook has any invalid patterns,
+// or if it does not have exactly one of script or command.
+func (h *Hook) Validate() error {
+	if h.Script == "" && len(h.Command) == 0 {
+		return fmt.Errorf("hook %s: must have a script or a command", h.Name)
+	}
+	if h.Script != "" && len(h.Command) > 0 {
+		return fmt.Errorf("hook %s: cannot have both a script and a command", h.Name)
+	}
+	if h.Shell != "" && len(h.Command) > 0 {
+		return fmt.Errorf("hook %s: shell can only be used with a script", h.Name)
+	}
+	for _, stream := range h.Streams {
+		if stream != "stdout" && stream != "stderr" {
+			return fmt.Errorf("hook %s: invalid stream %q: must be stdout or stderr", h.Name, stream)
+		}
+	}
+	for _, when := range Events {
+		for _, pattern := range h.Patterns(when) {
+			if err := validatePattern(pattern); err != nil {
+				return fmt.Errorf("hook %s: %s: %w", h.Name, when, err)
+			}
+		}
+	}
+	for _, pattern := range h.Export {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("hook %s: invalid export pattern %q: %w", h.Name, pattern, err)
+		}
+	}
+	if h.Retry != nil {
+		if err := h.Retry.Validate(); err != nil {
+			return fmt.Errorf("hook %s: %w", h.Name, err)
+		}
+	}
+	if h.Cache != nil {
+		if len(h.Output) > 0 {
+			return fmt.Errorf("hook %s: cache cannot be used with output filters", h.Name)
+		}
+		if err := h.Cache.Validate(); err != nil {
+			return fmt.Errorf("hook %s: %w", h.Name, err)
+		}
+	}
+	if h.If != "" {
+		if _, err := parseCondition(h.If); err != nil {
+			return fmt.Errorf("hook %s: %w", h.Name, err)
+		}
+	}
+	return nil
+}
+
+// Check reports whether the hook's condition is true, or if it has no condition.
+func (h *Hook) Check(event *Event, env ltf.Environ) (bool, error) {
+	if h.If == "" {
+		return true, nil
+	}
+	ok, err := evalCondition(h.If, event, env)
+	if err != nil {
+		return false, fmt.Errorf("hook %s: %w", h.Name, err)
+	}
+	return ok, nil
+}
+
+// Patterns returns the hook's command patterns for the given event.
+func (h *Hook) Patterns(when string) []string {
 	if when == "before" {
-		hookCmds = h.Before
+		return h.Before
 	} else if when == "after" {
-		hookCmds = h.After
+		return h.After
 	} else if when == "failed" {
-		hookCmds = h.Failed
+		return h.Failed
+	} else if when == "finally" {
+		return h.Finally
+	} else if when == "on_error" {
+		return h.OnError
+	} else if when == "output" {
+		return h.Output
 	}
-	for _, hookCmd := range hookCmds {
-		if hookCmd == "terraform" {
-			return true
-		} else if args.Subcommand != "" && hookCmd == "terraform "+args.Subcommand {
+	return nil
+}
+
+// inputEnv returns the environment for running the hook, using the hook's
+// inherit_env and env settings. Event variables are added separately.
+func (h *Hook) inputEnv(env ltf.Environ) ltf.Environ {
+	input := env
+	if h.InheritEnv != nil && !*h.InheritEnv {
+		input = ltf.NewEnviron()
+		for _, name := range inheritedVariables {
+			if value := env.GetValue(name); value != "" {
+				input = input.SetValue(name, value)
+			}
+		}
+	}
+
+	names := []string{}
+	for name := range h.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		input = input.SetValue(name, h.Env[name])
+	}
+
+	return input
+}
+
+// outputEnv returns the original environment with the changes that the hook
+// made to its input environment, only including variables allowed by the
+// hook's export settings. LTF_SKIP_TERRAFORM is always allowed.
+func (h *Hook) outputEnv(original ltf.Environ, input ltf.Environ, modified ltf.Environ) ltf.Environ {
+	changes := envChanges(input, modified)
+
+	names := []string{}
+	for name := range changes {
+		if h.exports(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	output := original
+	for _, name := range names {
+		if value := changes[name]; value == nil {
+			output = output.UnsetValue(name)
+		} else {
+			output = output.SetValue(name, *value)
+		}
+	}
+	return output
+}
+
+// exports reports whether the hook can pass on the named environment variable.
+func (h *Hook) exports(name string) bool {
+	if len(h.Export) == 0 || name == "LTF_SKIP_TERRAFORM" {
+		return true
+	}
+	for _, pattern := range h.Export {
+		if matched, _ := path.Match(pattern, name); matched {
 			return true
 		}
 	}
 	return false
 }
 
-// Run executes the hook script and returns the potentially modified environment variables.
-func (h *Hook) Run(env ltf.Environ) (modifiedEnv ltf.Environ, err error) {
-	fmt.Fprintf(os.Stderr, "# %s\n", h.Name)
+// Result holds the changes made by a hook that ran successfully.
+type Result struct {
+	// Env is the potentially modified environment.
+	Env ltf.Environ
 
-	hookCmd := exec.Command("bash", "-c", scriptPreamble+h.Script)
-	hookCmd.Env = env
-	hookCmd.Stdin = os.Stdin
-	hookCmd.Stderr = os.Stderr
+	// Vars holds Terraform variable values that the hook wrote to $LTF_VARS_FILE.
+	Vars map[string]interface{}
+
+	// Args holds the command line arguments from $LTF_ARGS_FILE,
+	// or nil if the hook did not change them.
+	Args []string
+}
 
-	stdout, err := hookCmd.StdoutPipe()
+// Run executes the hook script and returns the changes that it made.
+// The args are the command line arguments after "ltf", which the hook
+// can change by editing the file named by $LTF_ARGS_FILE.
+//
+// Bash scripts export their whole environment when they finish, using a wrapper
+// script. Other shells and commands can write variables to the file named by
+// $LTF_ENV_FILE instead, which also works with Bash scripts.
+func (h *Hook) Run(env ltf.Environ, args []string) (*Result, error) {
+	fmt.Fprintf(os.Stderr, "# %s\n", h.Name)
+
+	envFile, err := tempFile("ltf-env-", "")
 	if err != nil {
 		return nil, err
 	}
+	defer os.Remove(envFile)
 
-	if err := hookCmd.Start(); err != nil {
+	varsFile, err := tempFile("ltf-vars-", "")
+	if err != nil {
 		return nil, err
 	}
+	defer os.Remove(varsFile)
 
-	bytes, err := ioutil.ReadAll(stdout)
+	argsFile, err := tempFile("ltf-args-", formatArgsFile(args))
 	if err != nil {
 		return nil, err
 	}
+	defer os.Remove(argsFile)
+
+	wrapped := len(h.Command) == 0 && (h.Shell == "" || h.Shell == "bash")
 
-	if err := hookCmd.Wait(); err != nil {
+	var hookCmd *exec.Cmd
+	if len(h.Command) > 0 {
+		hookCmd = exec.Command(h.Command[0], h.Command[1:]...)
+	} else if wrapped {
+		hookCmd = exec.Command("bash", "-c", scriptPreamble+h.Script)
+	} else {
+		hookCmd = exec.Command(h.Shell, "-c", h.Script)
+	}
+	hookCmd.Env = env.SetValue("LTF_ENV_FILE", envFile).SetValue("LTF_VARS_FILE", varsFile).SetValue("LTF_ARGS_FILE", argsFile)
+	hookCmd.Stdin = os.Stdin
+	hookCmd.Stderr = os.Stderr
+
+	var modifiedEnv ltf.Environ
+	if wrapped {
+		stdout, err := hookCmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+
+		p, err := process.Start(hookCmd, h.Timeout)
+		if err != nil {
+			return nil, err
+		}
+
+		bytes, err := ioutil.ReadAll(stdout)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.Wait(); err != nil {
+			return nil, err
+		}
+
+		if len(bytes) == 0 {
+			return nil, errors.New("wrapper script failed to output environment variables")
+		}
+
+		err = json.Unmarshal(bytes, &modifiedEnv)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		hookCmd.Stdout = os.Stderr
+		if err := process.Run(hookCmd, h.Timeout); err != nil {
+			return nil, err
+		}
+		modifiedEnv = env
+	}
+
+	result := &Result{}
+
+	content, err := ioutil.ReadFile(envFile)
+	if err != nil {
+		return nil, err
+	}
+	modifiedEnv, err = parseEnvFile(content, modifiedEnv)
+	if err != nil {
 		return nil, err
 	}
+	for _, name := range []string{"LTF_ENV_FILE", "LTF_VARS_FILE", "LTF_ARGS_FILE"} {
+		modifiedEnv = modifiedEnv.UnsetValue(name)
+	}
+	result.Env = modifiedEnv
 
-	if len(bytes) == 0 {
-		return nil, errors.New("wrapper script failed to output environment variables")
+	content, err = ioutil.ReadFile(varsFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(strings.TrimSpace(string(content))) > 0 {
+		result.Vars, err = variable.ParseValues(content, "LTF_VARS_FILE")
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	err = json.Unmarshal(bytes, &modifiedEnv)
+	content, err = ioutil.ReadFile(argsFile)
 	if err != nil {
 		return nil, err
 	}
+	if string(content) != formatArgsFile(args) {
+		result.Args = parseArgsFile(content)
+	}
 
-	return modifiedEnv, nil
+	return result, nil
+}
+
+// tempFile creates a temporary file with the given content and returns its path.
+func tempFile(prefix string, content string) (string, error) {
+	f, err := ioutil.TempFile("", prefix)
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
 }
@@ -96,4 +96,128 @@ func TestHookMatch(t *testing.T) {
 			is.Equal(h.Match("failed", args), false)
 		})
 	})
+	t.Run("negated patterns", func(t *testing.T) {
+		// Arrange
+
+		h := Hook{
+			Before: []string{"terraform plan", "terraform apply", "!terraform * -destroy"},
+		}
+
+		t.Run("without negated flag", func(t *testing.T) {
+			// Act
+
+			args, err := arguments.New([]string{"terraform", "apply"}, ltf.NewEnviron())
+			is.NoErr(err)
+
+			// Assert
+
+			is.Equal(h.Match("before", args), true)
+		})
+
+		t.Run("with negated flag", func(t *testing.T) {
+			// Act
+
+			args, err := arguments.New([]string{"terraform", "plan", "-destroy"}, ltf.NewEnviron())
+			is.NoErr(err)
+
+			// Assert
+
+			is.Equal(h.Match("before", args), false)
+		})
+	})
+}
+
+func TestHookRun(t *testing.T) {
+	t.Run("shell", func(t *testing.T) {
+		is := is.New(t)
+
+		// Arrange
+
+		h := Hook{
+			Name:   "sh",
+			Shell:  "sh",
+			Script: `echo "GREETING=hello $NAME" >> "$LTF_ENV_FILE"`,
+		}
+		env := ltf.NewEnviron("NAME=world")
+
+		// Act
+
+		result, err := h.Run(env, nil)
+
+		// Assert
+
+		is.NoErr(err)
+		is.Equal(result.Env.GetValue("GREETING"), "hello world")
+		is.Equal(result.Env.GetValue("NAME"), "world")
+		is.Equal(result.Env.GetValue("LTF_ENV_FILE"), "") // env file should not be exported
+	})
+
+	t.Run("command", func(t *testing.T) {
+		is := is.New(t)
+
+		// Arrange
+
+		h := Hook{
+			Name:    "command",
+			Command: []string{"sh", "-c", `printf 'LINES<<END\none\ntwo\nEND\n' > "$LTF_ENV_FILE"`},
+		}
+
+		// Act
+
+		result, err := h.Run(ltf.NewEnviron(), nil)
+
+		// Assert
+
+		is.NoErr(err)
+		is.Equal(result.Env.GetValue("LINES"), "one\ntwo")
+	})
+
+	t.Run("vars file", func(t *testing.T) {
+		is := is.New(t)
+
+		// Arrange
+
+		h := Hook{
+			Name:   "vars",
+			Shell:  "sh",
+			Script: `printf 'tags = { team = "platform" }\nzones = ["a", "b"]\n' > "$LTF_VARS_FILE"`,
+		}
+
+		// Act
+
+		result, err := h.Run(ltf.NewEnviron(), nil)
+
+		// Assert
+
+		is.NoErr(err)
+		is.Equal(result.Vars["tags"], map[string]interface{}{"team": "platform"})
+		is.Equal(result.Vars["zones"], []interface{}{"a", "b"})
+		is.Equal(result.Env.GetValue("LTF_VARS_FILE"), "") // vars file should not be exported
+	})
+
+	t.Run("command fails", func(t *testing.T) {
+		is := is.New(t)
+
+		h := Hook{
+			Name:    "command",
+			Command: []string{"sh", "-c", `echo X=1 > "$LTF_ENV_FILE"; exit 1`},
+		}
+
+		_, err := h.Run(ltf.NewEnviron(), nil)
+
+		is.True(err != nil)
+	})
+}
+
+func TestHookValidate(t *testing.T) {
+	is := is.New(t)
+
+	is.NoErr((&Hook{Script: "true"}).Validate())
+	is.NoErr((&Hook{Shell: "sh", Script: "true"}).Validate())
+	is.NoErr((&Hook{Command: []string{"true"}}).Validate())
+	is.True((&Hook{}).Validate() != nil)                                          // needs script or command
+	is.True((&Hook{Script: "true", Command: []string{"true"}}).Validate() != nil) // not both
+	is.True((&Hook{Shell: "sh", Command: []string{"true"}}).Validate() != nil)    // shell needs script
+	is.True((&Hook{Script: "true", Before: []string{"plan"}}).Validate() != nil)  // invalid pattern
+	is.True((&Hook{Script: "true", If: "var.env =="}).Validate() != nil)          // invalid condition
 }
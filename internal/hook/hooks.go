@@ -2,40 +2,424 @@ package hook
 
 import (
 	"fmt"
+	"os"
 	"os/exec"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 
+	"github.com/raymondbutcher/ltf"
 	"github.com/raymondbutcher/ltf/internal/arguments"
-	"github.com/raymondbutcher/ltf/internal/variable"
+	"github.com/raymondbutcher/ltf/internal/redact"
 )
 
 type Hooks map[string]*Hook
 
-func (m Hooks) Run(when string, cmd *exec.Cmd, args *arguments.Arguments, vars variable.Variables) error {
-	for _, h := range m {
-		if h.Match(when, args) {
-			modifiedEnv, err := h.Run(cmd.Env)
-			if err != nil {
+// Run executes the hooks matching the given event.
+// Terraform variables written to $LTF_VARS_FILE by hooks are set in the
+// event's variables and exported as TF_VAR_name environment variables.
+// Command line arguments written to $LTF_ARGS_FILE are parsed and replace
+// the event's arguments in place, so the caller and subsequent hooks see
+// the final command.
+// If a "before" hook requests that Terraform should be skipped, by calling
+// ltf_skip_terraform or exporting LTF_SKIP_TERRAFORM, then no further hooks
+// are run and a *SkipError is returned.
+func (m Hooks) Run(event *Event, cmd *exec.Cmd) error {
+	steps, err := m.Steps()
+	if err != nil {
+		return err
+	}
+	for _, step := range steps {
+		hooks := []*Hook{}
+		for _, h := range step {
+			if !h.Match(event.When, event.Args) {
+				continue
+			}
+			if ok, err := h.Check(event, event.environ(h.Name, cmd.Env)); err != nil {
 				return err
+			} else if !ok {
+				continue
 			}
+			hooks = append(hooks, h)
+		}
 
-			for _, env := range modifiedEnv {
-				s := strings.SplitN(env, "=", 2)
-				if len(s) == 2 {
-					name := s[0]
-					if len(name) > 7 && name[:7] == "TF_VAR_" {
-						name = name[7:]
-						value := s[1]
-						v, err := vars.SetValue(name, value, false)
-						if err != nil {
-							return fmt.Errorf("hook %s: %w", h.Name, err)
-						}
-						v.Print()
+		var result *Result
+		var results []*Result
+		if len(hooks) == 0 {
+			continue
+		} else if len(hooks) == 1 {
+			result, err = runHook(hooks[0], event, cmd.Env)
+			results = []*Result{result}
+		} else {
+			result, results, err = runGroup(hooks, event, cmd.Env)
+		}
+		if err != nil {
+			return err
+		}
+
+		label := "hook " + hooks[0].Name
+		if len(hooks) > 1 {
+			label = "group " + hooks[0].Group
+		}
+
+		// Redact secret values from output, and make secret
+		// Terraform variables sensitive before printing them.
+		sensitive := map[string]bool{}
+		for _, h := range hooks {
+			for _, name := range h.SecretEnv {
+				redact.Add(result.Env.GetValue(name))
+				if strings.HasPrefix(name, "TF_VAR_") {
+					sensitive[name[7:]] = true
+				}
+			}
+		}
+
+		modifiedEnv := result.Env
+		for _, env := range modifiedEnv {
+			s := strings.SplitN(env, "=", 2)
+			if len(s) == 2 {
+				name := s[0]
+				if len(name) > 7 && name[:7] == "TF_VAR_" {
+					name = name[7:]
+					value := s[1]
+					v, err := event.Vars.SetValue(name, value, false)
+					if err != nil {
+						return fmt.Errorf("%s: %w", label, err)
+					}
+					if sensitive[name] {
+						v.Sensitive = true
 					}
+					v.Print()
+				}
+			}
+		}
+
+		// Set typed variables from $LTF_VARS_FILE. These take precedence
+		// over TF_VAR_name environment variables exported by the same hooks.
+		names := []string{}
+		for name := range result.Vars {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			v, err := event.Vars.SetAnyValue(name, result.Vars[name], false)
+			if err != nil {
+				return fmt.Errorf("%s: %w", label, err)
+			}
+			if sensitive[name] {
+				v.Sensitive = true
+				redact.Add(v.StringValue)
+			}
+			modifiedEnv = modifiedEnv.SetValue("TF_VAR_"+name, v.StringValue)
+			v.Print()
+		}
+
+		// Use the command line arguments from $LTF_ARGS_FILE.
+		// Variables from -var and -var-file arguments are frozen,
+		// the same as when LTF loads them from the original arguments.
+		if result.Args != nil {
+			args, err := arguments.New(append([]string{event.Args.Bin}, result.Args...), modifiedEnv)
+			if err != nil {
+				return fmt.Errorf("%s: %w", label, err)
+			}
+			if event.Vars != nil {
+				changed, err := event.Vars.LoadArgs(args)
+				if err != nil {
+					return fmt.Errorf("%s: %w", label, err)
+				}
+				for _, v := range changed {
+					modifiedEnv = modifiedEnv.SetValue("TF_VAR_"+v.Name, v.StringValue)
+					v.Print()
+				}
+			}
+			*event.Args = *args
+			redact.Fprintf(os.Stderr, "+ ltf %s\n", strings.Join(args.Args[1:], " "))
+		}
+
+		originalEnv := ltf.Environ(cmd.Env)
+		cmd.Env = modifiedEnv
+
+		if event.When == "before" {
+			for i, h := range hooks {
+				skip := results[i].Env.GetValue("LTF_SKIP_TERRAFORM")
+				if skip == "" || skip == originalEnv.GetValue("LTF_SKIP_TERRAFORM") {
+					continue
+				}
+				exitStatus, err := strconv.Atoi(skip)
+				if err != nil {
+					return fmt.Errorf("hook %s: invalid LTF_SKIP_TERRAFORM value: %s", h.Name, skip)
 				}
+				cmd.Env = modifiedEnv.UnsetValue("LTF_SKIP_TERRAFORM")
+				return &SkipError{Hook: h.Name, ExitStatus: exitStatus}
 			}
-			cmd.Env = modifiedEnv
 		}
 	}
 	return nil
 }
+
+// runHook runs a single hook and returns the changes that it made.
+// Hooks with cache settings reuse their previous changes to the
+// environment, variables and arguments until the cache expires.
+func runHook(h *Hook, event *Event, env ltf.Environ) (*Result, error) {
+	cacheFile := ""
+	if h.Cache != nil {
+		var err error
+		cacheFile, err = h.cacheFile(event, env)
+		if err != nil {
+			return nil, fmt.Errorf("hook %s: %w", h.Name, err)
+		}
+		if cacheFile != "" {
+			if result, found := readCache(cacheFile, env, event.Args.Args[1:]); found {
+				fmt.Fprintf(os.Stderr, "# %s (cached)\n", h.Name)
+				return result, nil
+			}
+		}
+	}
+
+	input := h.inputEnv(env)
+	result, err := h.runWithRetry(event.environ(h.Name, input), event.Args.Args[1:])
+	if err != nil {
+		return nil, fmt.Errorf("hook %s: %w", h.Name, err)
+	}
+	result.Env = h.outputEnv(env, input, restoreEnviron(result.Env, input))
+
+	if cacheFile != "" {
+		// The hook succeeded, so only show a warning if caching fails.
+		if err := writeCache(cacheFile, h.Cache.TTL, env, event.Args.Args[1:], result); err != nil {
+			fmt.Fprintf(os.Stderr, "# %s: warning: error writing cache: %s\n", h.Name, err)
+		}
+	}
+
+	return result, nil
+}
+
+// runGroup runs hooks concurrently, all starting with the same environment.
+// It returns the changes from all of the hooks merged together, along with
+// the changes made by each hook. It returns an error if any hook fails, or if
+// hooks change the same environment variable, Terraform variable, or the
+// command line arguments to different values.
+func runGroup(hooks []*Hook, event *Event, env ltf.Environ) (*Result, []*Result, error) {
+	results := make([]*Result, len(hooks))
+	errs := make([]error, len(hooks))
+
+	var wg sync.WaitGroup
+	for i, h := range hooks {
+		wg.Add(1)
+		go func(i int, h *Hook) {
+			defer wg.Done()
+			results[i], errs[i] = runHook(h, event, env)
+		}(i, h)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	merged := &Result{Vars: map[string]interface{}{}}
+	valueSetBy := map[string]string{}
+	argsSetBy := ""
+	for i, h := range hooks {
+		for name, value := range results[i].Vars {
+			if other, found := merged.Vars[name]; found && !reflect.DeepEqual(other, value) {
+				return nil, nil, fmt.Errorf("group %s: hooks %s and %s set variable %s to different values", h.Group, valueSetBy[name], h.Name, name)
+			}
+			merged.Vars[name] = value
+			valueSetBy[name] = h.Name
+		}
+		if args := results[i].Args; args != nil {
+			if merged.Args != nil && !reflect.DeepEqual(merged.Args, args) {
+				return nil, nil, fmt.Errorf("group %s: hooks %s and %s set the arguments to different values", h.Group, argsSetBy, h.Name)
+			}
+			merged.Args = args
+			argsSetBy = h.Name
+		}
+	}
+
+	// Merge the environment changes, checking for conflicts. A nil value
+	// means that the variable was removed from the environment.
+	changes := map[string]*string{}
+	setBy := map[string]string{}
+	for i, h := range hooks {
+		for name, value := range envChanges(env, results[i].Env) {
+			if other, found := changes[name]; found {
+				if (other == nil) != (value == nil) || (other != nil && *other != *value) {
+					return nil, nil, fmt.Errorf("group %s: hooks %s and %s set %s to different values", h.Group, setBy[name], h.Name, name)
+				}
+			}
+			changes[name] = value
+			setBy[name] = h.Name
+		}
+	}
+
+	names := []string{}
+	for name := range changes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	merged.Env = env
+	for _, name := range names {
+		if value := changes[name]; value == nil {
+			merged.Env = merged.Env.UnsetValue(name)
+		} else {
+			merged.Env = merged.Env.SetValue(name, *value)
+		}
+	}
+
+	return merged, results, nil
+}
+
+// envChanges returns the variables that are different in the modified environment.
+// Variables that were removed have nil values.
+func envChanges(original ltf.Environ, modified ltf.Environ) map[string]*string {
+	toMap := func(env ltf.Environ) map[string]string {
+		m := map[string]string{}
+		for _, item := range env {
+			s := strings.SplitN(item, "=", 2)
+			if len(s) == 2 {
+				m[s[0]] = s[1]
+			}
+		}
+		return m
+	}
+	before := toMap(original)
+	after := toMap(modified)
+
+	changes := map[string]*string{}
+	for name, value := range after {
+		if old, found := before[name]; !found || old != value {
+			value := value
+			changes[name] = &value
+		}
+	}
+	for name := range before {
+		if _, found := after[name]; !found {
+			changes[name] = nil
+		}
+	}
+	return changes
+}
+
+// Sorted returns the hooks in the order that they should run.
+// Hooks run after the hooks listed in their depends_on field,
+// and otherwise in alphabetical order of their names.
+// It returns an error if a hook depends on an unknown hook,
+// or if there is a dependency cycle.
+func (m Hooks) Sorted() ([]*Hook, error) {
+	steps, err := m.Steps()
+	if err != nil {
+		return nil, err
+	}
+	sorted := []*Hook{}
+	for _, step := range steps {
+		sorted = append(sorted, step...)
+	}
+	return sorted, nil
+}
+
+// Steps returns the hooks in the order that they should run, split into steps.
+// Hooks in the same group share a step, and run concurrently. Other hooks have
+// a step of their own. A group is ordered by the first hook name in the group,
+// and it runs after the dependencies of all of its hooks.
+func (m Hooks) Steps() ([][]*Hook, error) {
+	// Each unit is either a group of hooks or a single hook without a group.
+	unitOf := map[string]string{}
+	members := map[string][]string{}
+	for name, h := range m {
+		unit := name
+		if h.Group != "" {
+			unit = "group " + h.Group
+		}
+		unitOf[name] = unit
+		members[unit] = append(members[unit], name)
+	}
+
+	units := []string{}
+	for unit, names := range members {
+		sort.Strings(names)
+		units = append(units, unit)
+	}
+	sort.Slice(units, func(i, j int) bool {
+		return members[units[i]][0] < members[units[j]][0]
+	})
+
+	// Find the units that each unit depends on.
+	unitDeps := map[string][]string{}
+	for _, unit := range units {
+		seen := map[string]bool{}
+		for _, name := range members[unit] {
+			deps := append([]string{}, m[name].DependsOn...)
+			sort.Strings(deps)
+			for _, dep := range deps {
+				depUnit, found := unitOf[dep]
+				if !found {
+					return nil, fmt.Errorf("hook %s depends on unknown hook %s", name, dep)
+				}
+				if dep == name {
+					return nil, fmt.Errorf("hook dependency cycle: %s -> %s", name, name)
+				}
+				if depUnit == unit {
+					return nil, fmt.Errorf("hook %s depends on hook %s in the same group", name, dep)
+				}
+				if !seen[depUnit] {
+					seen[depUnit] = true
+					unitDeps[unit] = append(unitDeps[unit], depUnit)
+				}
+			}
+		}
+	}
+
+	steps := [][]*Hook{}
+	done := map[string]bool{}
+	visiting := map[string]bool{}
+	path := []string{}
+
+	var visit func(unit string) error
+	visit = func(unit string) error {
+		if done[unit] {
+			return nil
+		}
+		if visiting[unit] {
+			// Report the hooks involved in the cycle, starting and
+			// ending with the hook that was found twice.
+			for i, u := range path {
+				if u == unit {
+					cycle := append(append([]string{}, path[i:]...), unit)
+					return fmt.Errorf("hook dependency cycle: %s", strings.Join(cycle, " -> "))
+				}
+			}
+		}
+		visiting[unit] = true
+		path = append(path, unit)
+
+		for _, dep := range unitDeps[unit] {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+
+		path = path[:len(path)-1]
+		visiting[unit] = false
+		done[unit] = true
+
+		step := []*Hook{}
+		for _, name := range members[unit] {
+			step = append(step, m[name])
+		}
+		steps = append(steps, step)
+		return nil
+	}
+
+	for _, unit := range units {
+		if err := visit(unit); err != nil {
+			return nil, err
+		}
+	}
+
+	return steps, nil
+}
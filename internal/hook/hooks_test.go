@@ -0,0 +1,316 @@
+package hook
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/raymondbutcher/ltf"
+	"github.com/raymondbutcher/ltf/internal/arguments"
+	"github.com/raymondbutcher/ltf/internal/redact"
+	"github.com/raymondbutcher/ltf/internal/variable"
+)
+
+func TestHooksSorted(t *testing.T) {
+	names := func(hooks []*Hook) []string {
+		result := []string{}
+		for _, h := range hooks {
+			result = append(result, h.Name)
+		}
+		return result
+	}
+
+	t.Run("alphabetical without dependencies", func(t *testing.T) {
+		is := is.New(t)
+
+		// Arrange
+
+		hooks := Hooks{
+			"c": &Hook{Name: "c"},
+			"a": &Hook{Name: "a"},
+			"b": &Hook{Name: "b"},
+		}
+
+		// Act
+
+		sorted, err := hooks.Sorted()
+
+		// Assert
+
+		is.NoErr(err)
+		is.Equal(names(sorted), []string{"a", "b", "c"})
+	})
+
+	t.Run("dependencies run first", func(t *testing.T) {
+		is := is.New(t)
+
+		// Arrange
+
+		hooks := Hooks{
+			"a use credentials":    &Hook{Name: "a use credentials", DependsOn: []string{"b export credentials"}},
+			"b export credentials": &Hook{Name: "b export credentials", DependsOn: []string{"c login"}},
+			"c login":              &Hook{Name: "c login"},
+			"d unrelated":          &Hook{Name: "d unrelated"},
+		}
+
+		// Act
+
+		sorted, err := hooks.Sorted()
+
+		// Assert
+
+		is.NoErr(err)
+		is.Equal(names(sorted), []string{"c login", "b export credentials", "a use credentials", "d unrelated"})
+	})
+
+	t.Run("unknown dependency", func(t *testing.T) {
+		is := is.New(t)
+
+		// Arrange
+
+		hooks := Hooks{
+			"a": &Hook{Name: "a", DependsOn: []string{"missing"}},
+		}
+
+		// Act
+
+		_, err := hooks.Sorted()
+
+		// Assert
+
+		is.True(err != nil)
+		is.Equal(err.Error(), "hook a depends on unknown hook missing")
+	})
+
+	t.Run("dependency cycle", func(t *testing.T) {
+		is := is.New(t)
+
+		// Arrange
+
+		hooks := Hooks{
+			"a": &Hook{Name: "a", DependsOn: []string{"b"}},
+			"b": &Hook{Name: "b", DependsOn: []string{"c"}},
+			"c": &Hook{Name: "c", DependsOn: []string{"a"}},
+			"d": &Hook{Name: "d"},
+		}
+
+		// Act
+
+		_, err := hooks.Sorted()
+
+		// Assert
+
+		is.True(err != nil)
+		is.Equal(err.Error(), "hook dependency cycle: a -> b -> c -> a")
+	})
+}
+
+func TestHooksSteps(t *testing.T) {
+	is := is.New(t)
+
+	// Arrange
+
+	hooks := Hooks{
+		"a login":         &Hook{Name: "a login"},
+		"b secrets one":   &Hook{Name: "b secrets one", Group: "secrets", DependsOn: []string{"a login"}},
+		"c secrets two":   &Hook{Name: "c secrets two", Group: "secrets"},
+		"d use secrets":   &Hook{Name: "d use secrets", DependsOn: []string{"c secrets two"}},
+		"0 before groups": &Hook{Name: "0 before groups"},
+	}
+
+	// Act
+
+	steps, err := hooks.Steps()
+
+	// Assert
+
+	is.NoErr(err)
+	result := [][]string{}
+	for _, step := range steps {
+		names := []string{}
+		for _, h := range step {
+			names = append(names, h.Name)
+		}
+		result = append(result, names)
+	}
+	is.Equal(result, [][]string{
+		{"0 before groups"},
+		{"a login"},
+		{"b secrets one", "c secrets two"},
+		{"d use secrets"},
+	})
+
+	t.Run("dependency in the same group", func(t *testing.T) {
+		is := is.New(t)
+
+		hooks := Hooks{
+			"a": &Hook{Name: "a", Group: "g"},
+			"b": &Hook{Name: "b", Group: "g", DependsOn: []string{"a"}},
+		}
+
+		_, err := hooks.Steps()
+
+		is.True(err != nil)
+		is.Equal(err.Error(), "hook b depends on hook a in the same group")
+	})
+}
+
+func TestRunGroup(t *testing.T) {
+	args, err := arguments.New([]string{"ltf", "plan"}, ltf.NewEnviron())
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := &Event{When: "before", Args: args}
+	env := ltf.NewEnviron("PATH=/usr/bin:/bin", "SHARED=original")
+
+	t.Run("merges environments", func(t *testing.T) {
+		is := is.New(t)
+
+		// Arrange
+
+		hooks := []*Hook{
+			{Name: "one", Group: "g", Shell: "sh", Script: `echo ONE=1 >> "$LTF_ENV_FILE"; echo SHARED=same >> "$LTF_ENV_FILE"`},
+			{Name: "two", Group: "g", Shell: "sh", Script: `echo TWO=2 >> "$LTF_ENV_FILE"; echo SHARED=same >> "$LTF_ENV_FILE"`},
+		}
+
+		// Act
+
+		merged, results, err := runGroup(hooks, event, env)
+
+		// Assert
+
+		is.NoErr(err)
+		is.Equal(len(results), 2)
+		is.Equal(merged.Env.GetValue("ONE"), "1")
+		is.Equal(merged.Env.GetValue("TWO"), "2")
+		is.Equal(merged.Env.GetValue("SHARED"), "same")
+		is.Equal(merged.Env.GetValue("PATH"), "/usr/bin:/bin")
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		is := is.New(t)
+
+		// Arrange
+
+		hooks := []*Hook{
+			{Name: "one", Group: "g", Shell: "sh", Script: `echo SHARED=one >> "$LTF_ENV_FILE"`},
+			{Name: "two", Group: "g", Shell: "sh", Script: `echo SHARED=two >> "$LTF_ENV_FILE"`},
+		}
+
+		// Act
+
+		_, _, err := runGroup(hooks, event, env)
+
+		// Assert
+
+		is.True(err != nil)
+		is.Equal(err.Error(), "group g: hooks one and two set SHARED to different values")
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		is := is.New(t)
+
+		hooks := []*Hook{
+			{Name: "one", Group: "g", Shell: "sh", Script: `exit 0`},
+			{Name: "two", Group: "g", Shell: "sh", Script: `exit 1`},
+		}
+
+		_, _, err := runGroup(hooks, event, env)
+
+		is.True(err != nil)
+		is.Equal(err.Error(), "hook two: exit status 1")
+	})
+}
+
+func TestHooksRunSecretEnv(t *testing.T) {
+	is := is.New(t)
+
+	// Arrange
+
+	args, err := arguments.New([]string{"ltf", "plan"}, ltf.NewEnviron())
+	is.NoErr(err)
+	vars := variable.Variables{}
+	event := &Event{When: "before", Args: args, Vars: vars}
+	hooks := Hooks{
+		"secrets": &Hook{
+			Name:      "secrets",
+			Before:    []string{"terraform"},
+			Shell:     "sh",
+			Script:    `echo TF_VAR_db_password=hunter2-db >> "$LTF_ENV_FILE"; echo AWS_SESSION_TOKEN=hunter2-aws >> "$LTF_ENV_FILE"`,
+			SecretEnv: []string{"TF_VAR_db_password", "AWS_SESSION_TOKEN"},
+		},
+	}
+	cmd := exec.Command("terraform", "plan")
+	cmd.Env = ltf.NewEnviron()
+
+	// Act
+
+	err = hooks.Run(event, cmd)
+
+	// Assert
+
+	is.NoErr(err)
+	is.True(vars["db_password"].Sensitive)
+	is.Equal(ltf.Environ(cmd.Env).GetValue("AWS_SESSION_TOKEN"), "hunter2-aws")
+	is.Equal(redact.String("db=hunter2-db aws=hunter2-aws"), "db=(sensitive value) aws=(sensitive value)")
+}
+
+func TestRunHookEnv(t *testing.T) {
+	args, err := arguments.New([]string{"ltf", "plan"}, ltf.NewEnviron())
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := &Event{When: "before", Args: args}
+	env := ltf.NewEnviron("PATH=/usr/bin:/bin", "SECRET=original", "KEEP=yes")
+	inherit := false
+
+	t.Run("static env and inherit_env", func(t *testing.T) {
+		is := is.New(t)
+
+		// Arrange
+
+		h := &Hook{
+			Name:       "isolated",
+			Shell:      "sh",
+			Script:     `echo "SEEN=${SECRET:-unset} $GREETING $PATH" >> "$LTF_ENV_FILE"`,
+			Env:        map[string]string{"GREETING": "hello"},
+			InheritEnv: &inherit,
+		}
+
+		// Act
+
+		result, err := runHook(h, event, env)
+
+		// Assert
+
+		is.NoErr(err)
+		is.Equal(result.Env.GetValue("SEEN"), "unset hello /usr/bin:/bin")
+		is.Equal(result.Env.GetValue("GREETING"), "") // static env is not passed on
+		is.Equal(result.Env.GetValue("SECRET"), "original")
+		is.Equal(result.Env.GetValue("KEEP"), "yes")
+	})
+
+	t.Run("export allowlist", func(t *testing.T) {
+		is := is.New(t)
+
+		// Arrange
+
+		h := &Hook{
+			Name:   "allowlist",
+			Shell:  "sh",
+			Script: `printf 'PATH=/tmp\nTF_VAR_a=1\nTEMP=1\n' >> "$LTF_ENV_FILE"`,
+			Export: []string{"TF_VAR_*"},
+		}
+
+		// Act
+
+		result, err := runHook(h, event, env)
+
+		// Assert
+
+		is.NoErr(err)
+		is.Equal(result.Env.GetValue("TF_VAR_a"), "1")
+		is.Equal(result.Env.GetValue("TEMP"), "")
+		is.Equal(result.Env.GetValue("PATH"), "/usr/bin:/bin")
+	})
+}
@@ -0,0 +1,123 @@
+package hook
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/raymondbutcher/ltf/internal/arguments"
+)
+
+// matchCommand reports whether a hook's command pattern matches the arguments.
+//
+// Patterns can be written in these forms:
+//
+//	terraform                        matches every command
+//	terraform plan                   matches commands with these positional arguments
+//	terraform workspace select       positional arguments can be nested subcommands
+//	terraform state *                positional arguments can use glob patterns
+//	terraform apply -auto-approve    flags must be present anywhere in the command
+//	terraform * -destroy             globs and flags can be combined
+//	/^terraform (plan|apply)\b/      a regular expression between slashes is matched
+//	                                 against the full command line
+//
+// A pattern starting with "!" is negated. Negated patterns are
+// handled by Hook.Match to exclude commands from matching.
+func matchCommand(pattern string, args *arguments.Arguments) (bool, error) {
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return false, fmt.Errorf("invalid pattern %s: %w", pattern, err)
+		}
+		return re.MatchString(commandLine(args)), nil
+	}
+
+	words := strings.Fields(pattern)
+	if len(words) == 0 || words[0] != "terraform" {
+		return false, fmt.Errorf("invalid pattern %q: must start with terraform", pattern)
+	}
+
+	positionals, flags := splitArgs(args)
+	i := 0
+	for _, word := range words[1:] {
+		if strings.HasPrefix(word, "-") {
+			if ok, err := matchFlag(word, flags); err != nil {
+				return false, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			} else if !ok {
+				return false, nil
+			}
+		} else {
+			if i >= len(positionals) {
+				return false, nil
+			}
+			if ok, err := path.Match(word, positionals[i]); err != nil {
+				return false, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			} else if !ok {
+				return false, nil
+			}
+			i++
+		}
+	}
+	return true, nil
+}
+
+// validatePattern returns an error if the pattern is invalid.
+func validatePattern(pattern string) error {
+	pattern = strings.TrimSpace(strings.TrimPrefix(pattern, "!"))
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		if _, err := regexp.Compile(pattern[1 : len(pattern)-1]); err != nil {
+			return fmt.Errorf("invalid pattern %s: %w", pattern, err)
+		}
+		return nil
+	}
+	words := strings.Fields(pattern)
+	if len(words) == 0 || words[0] != "terraform" {
+		return fmt.Errorf("invalid pattern %q: must start with terraform", pattern)
+	}
+	for _, word := range words[1:] {
+		if _, err := path.Match(word, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// matchFlag reports whether any of the flags match the pattern.
+// A pattern without a value, such as "-destroy", also matches
+// the flag with any value, such as "-destroy=true".
+func matchFlag(pattern string, flags []string) (bool, error) {
+	for _, flag := range flags {
+		if ok, err := path.Match(pattern, flag); err != nil {
+			return false, err
+		} else if ok {
+			return true, nil
+		}
+		if !strings.Contains(pattern, "=") {
+			name := strings.SplitN(flag, "=", 2)[0]
+			if ok, _ := path.Match(pattern, name); ok {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// splitArgs returns the positional arguments and flags from the
+// combined arguments, not including the command itself.
+func splitArgs(args *arguments.Arguments) (positionals []string, flags []string) {
+	for _, arg := range args.Virtual[1:] {
+		if strings.HasPrefix(arg, "-") {
+			flags = append(flags, arg)
+		} else if arg != "" {
+			positionals = append(positionals, arg)
+		}
+	}
+	return positionals, flags
+}
+
+// commandLine returns the combined arguments as a single string,
+// starting with "terraform" rather than the LTF command.
+func commandLine(args *arguments.Arguments) string {
+	return strings.Join(append([]string{"terraform"}, args.Virtual[1:]...), " ")
+}
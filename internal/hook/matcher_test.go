@@ -0,0 +1,78 @@
+package hook
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/raymondbutcher/ltf"
+	"github.com/raymondbutcher/ltf/internal/arguments"
+)
+
+func TestMatchCommand(t *testing.T) {
+	tests := []struct {
+		pattern string
+		cmd     []string
+		want    bool
+	}{
+		{"terraform", []string{"ltf"}, true},
+		{"terraform", []string{"ltf", "plan"}, true},
+		{"terraform plan", []string{"ltf", "plan"}, true},
+		{"terraform plan", []string{"ltf", "-chdir=..", "plan", "-out=tfplan"}, true},
+		{"terraform plan", []string{"ltf", "apply"}, false},
+		{"terraform plan", []string{"ltf"}, false},
+		{"terraform workspace select", []string{"ltf", "workspace", "select", "dev"}, true},
+		{"terraform workspace select", []string{"ltf", "workspace", "new", "dev"}, false},
+		{"terraform state *", []string{"ltf", "state", "rm", "random_id.this"}, true},
+		{"terraform state *", []string{"ltf", "state"}, false},
+		{"terraform apply -auto-approve", []string{"ltf", "apply", "-auto-approve"}, true},
+		{"terraform apply -auto-approve", []string{"ltf", "apply"}, false},
+		{"terraform * -destroy", []string{"ltf", "plan", "-destroy"}, true},
+		{"terraform * -destroy", []string{"ltf", "apply", "-destroy=true"}, true},
+		{"terraform * -destroy", []string{"ltf", "plan"}, false},
+		{"terraform plan -var=env=*", []string{"ltf", "plan", "-var", "env=live"}, true},
+		{"/^terraform (plan|apply) .*-destroy/", []string{"ltf", "apply", "-destroy"}, true},
+		{"/^terraform (plan|apply) .*-destroy/", []string{"ltf", "destroy"}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.pattern, func(t *testing.T) {
+			is := is.New(t)
+
+			// Arrange
+
+			args, err := arguments.New(test.cmd, ltf.NewEnviron())
+			is.NoErr(err)
+
+			// Act
+
+			got, err := matchCommand(test.pattern, args)
+
+			// Assert
+
+			is.NoErr(err)
+			is.Equal(got, test.want) // unexpected match result
+		})
+	}
+
+	t.Run("flags from environment variables", func(t *testing.T) {
+		is := is.New(t)
+
+		args, err := arguments.New([]string{"ltf", "apply"}, ltf.NewEnviron("TF_CLI_ARGS_apply=-auto-approve"))
+		is.NoErr(err)
+
+		got, err := matchCommand("terraform apply -auto-approve", args)
+
+		is.NoErr(err)
+		is.True(got)
+	})
+}
+
+func TestValidatePattern(t *testing.T) {
+	is := is.New(t)
+
+	is.NoErr(validatePattern("terraform"))
+	is.NoErr(validatePattern("!terraform plan -destroy"))
+	is.NoErr(validatePattern("/^terraform plan/"))
+	is.True(validatePattern("plan") != nil)          // must start with terraform
+	is.True(validatePattern("terraform [") != nil)   // bad glob
+	is.True(validatePattern("/terraform (/") != nil) // bad regex
+}
@@ -0,0 +1,66 @@
+package hook
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/raymondbutcher/ltf"
+)
+
+// sleep is replaced in tests to avoid waiting between retries.
+var sleep = time.Sleep
+
+// Retry configures how a hook is retried after it fails.
+type Retry struct {
+	// Attempts is the maximum number of times to run the hook,
+	// including the first attempt.
+	Attempts int `yaml:"attempts,omitempty"`
+
+	// Delay is how long to wait before the first retry.
+	Delay time.Duration `yaml:"delay,omitempty"`
+
+	// Backoff multiplies the delay after each retry. For example, a delay of 1s
+	// and a backoff of 2 waits for 1s, 2s, 4s and so on. It defaults to 1.
+	Backoff float64 `yaml:"backoff,omitempty"`
+}
+
+// Validate returns an error if the retry settings are invalid.
+func (r *Retry) Validate() error {
+	if r.Attempts < 1 {
+		return fmt.Errorf("retry attempts must be at least 1")
+	}
+	if r.Delay < 0 {
+		return fmt.Errorf("retry delay cannot be negative")
+	}
+	if r.Backoff < 0 {
+		return fmt.Errorf("retry backoff cannot be negative")
+	}
+	return nil
+}
+
+// runWithRetry runs the hook, retrying it according to its retry settings.
+// The changes made by failed attempts are discarded,
+// and only the error from the last attempt is returned.
+func (h *Hook) runWithRetry(env ltf.Environ, args []string) (*Result, error) {
+	if h.Retry == nil || h.Retry.Attempts <= 1 {
+		return h.Run(env, args)
+	}
+
+	backoff := h.Retry.Backoff
+	if backoff == 0 {
+		backoff = 1
+	}
+	delay := h.Retry.Delay
+
+	for attempt := 1; ; attempt++ {
+		result, err := h.Run(env, args)
+		if err == nil || attempt >= h.Retry.Attempts {
+			return result, err
+		}
+		// Only the error from the last attempt is reported.
+		fmt.Fprintf(os.Stderr, "# %s failed on attempt %d of %d, retrying in %s\n", h.Name, attempt, h.Retry.Attempts, delay)
+		sleep(delay)
+		delay = time.Duration(float64(delay) * backoff)
+	}
+}
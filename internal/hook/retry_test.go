@@ -0,0 +1,86 @@
+package hook
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+	"github.com/raymondbutcher/ltf"
+)
+
+func TestRunWithRetry(t *testing.T) {
+	delays := []time.Duration{}
+	sleep = func(d time.Duration) { delays = append(delays, d) }
+	defer func() { sleep = time.Sleep }()
+
+	tempDir, err := os.MkdirTemp("", "ltf-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDir)
+	counter := path.Join(tempDir, "counter")
+
+	// The script fails until it has been run the given number of times.
+	// Each attempt exports a different variable, to check that
+	// the environment from failed attempts is discarded.
+	script := `n=$(($(cat "$COUNTER" 2>/dev/null || echo 0) + 1))
+echo $n > "$COUNTER"
+echo "ATTEMPT_$n=yes" >> "$LTF_ENV_FILE"
+[ $n -ge $SUCCEED_ON ]`
+
+	t.Run("succeeds after retrying", func(t *testing.T) {
+		is := is.New(t)
+
+		// Arrange
+
+		delays = nil
+		os.Remove(counter)
+		h := Hook{
+			Name:   "flaky",
+			Shell:  "sh",
+			Script: script,
+			Retry:  &Retry{Attempts: 4, Delay: time.Second, Backoff: 2},
+		}
+		env := ltf.NewEnviron("COUNTER="+counter, "SUCCEED_ON=3")
+
+		// Act
+
+		result, err := h.runWithRetry(env, nil)
+
+		// Assert
+
+		is.NoErr(err)
+		is.Equal(result.Env.GetValue("ATTEMPT_1"), "")
+		is.Equal(result.Env.GetValue("ATTEMPT_2"), "")
+		is.Equal(result.Env.GetValue("ATTEMPT_3"), "yes")
+		is.Equal(delays, []time.Duration{time.Second, 2 * time.Second})
+	})
+
+	t.Run("fails after all attempts", func(t *testing.T) {
+		is := is.New(t)
+
+		// Arrange
+
+		delays = nil
+		os.Remove(counter)
+		h := Hook{
+			Name:   "flaky",
+			Shell:  "sh",
+			Script: script,
+			Retry:  &Retry{Attempts: 2, Delay: time.Second},
+		}
+		env := ltf.NewEnviron("COUNTER="+counter, "SUCCEED_ON=5")
+
+		// Act
+
+		_, err := h.runWithRetry(env, nil)
+
+		// Assert
+
+		is.True(err != nil)
+		is.Equal(err.Error(), "exit status 1")
+		is.Equal(delays, []time.Duration{time.Second})
+	})
+}
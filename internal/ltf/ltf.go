@@ -2,20 +2,28 @@ package ltf
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
+	"reflect"
 	"strings"
+	"time"
 
+	"github.com/hashicorp/hcl/v2"
 	"github.com/raymondbutcher/ltf"
 	"github.com/raymondbutcher/ltf/internal/arguments"
 	"github.com/raymondbutcher/ltf/internal/backend"
+	"github.com/raymondbutcher/ltf/internal/command"
 	"github.com/raymondbutcher/ltf/internal/filesystem"
 	"github.com/raymondbutcher/ltf/internal/hook"
+	"github.com/raymondbutcher/ltf/internal/process"
+	"github.com/raymondbutcher/ltf/internal/redact"
 	"github.com/raymondbutcher/ltf/internal/settings"
 	"github.com/raymondbutcher/ltf/internal/variable"
+	"github.com/raymondbutcher/ltf/internal/versions"
 )
 
 const helpMessage = `LTF is a transparent wrapper for Terraform; it passes all command line
@@ -24,11 +32,79 @@ the current directory and parent directories for various Terraform files
 and alters the command line arguments and environment variables to make
 Terraform use them.
 
-LTF also executes hooks defined in the first 'ltf.yaml' file it finds
-in the current directory or parent directories. This can be used to run
-commands or modify the environment before and after Terraform runs.`
+LTF also executes hooks defined in 'ltf.yaml' or 'ltf.hcl' files in the
+current directory and parent directories. This can be used to run
+commands or modify the environment before and after Terraform runs, and
+custom commands which run scripts instead of Terraform. Run
+'ltf settings' to show the merged settings and which files they came
+from, 'ltf hooks' to show the hooks in the order that they run,
+'ltf hooks terraform plan' to show which hooks would run for a command,
+'ltf environments' to list environment directories and their metadata,
+'ltf schema' to print the JSON Schema for 'ltf.yaml' files, and
+'ltf cache clear' to remove cached hook results.`
+
+// lifecycle holds what Run has done so far, so "on_error" and
+// "finally" hooks can run after it returns.
+type lifecycle struct {
+	// hooks are the hooks from the settings files, or nil
+	// if they have not been loaded or should not run.
+	hooks hook.Hooks
+
+	// event describes the current run, and is updated as
+	// Run finds directories and loads variables.
+	event *hook.Event
+
+	// cmd is the Terraform command or custom command,
+	// once it has been built.
+	cmd *exec.Cmd
+
+	// started is true once the Terraform command or custom command has started.
+	started bool
+}
 
 func Run(cwd string, args *arguments.Arguments, env ltf.Environ) (cmd *exec.Cmd, exitStatus int, err error) {
+	l := &lifecycle{}
+	cmd, exitStatus, err = run(cwd, args, env, l)
+	if l.hooks == nil {
+		return cmd, exitStatus, err
+	}
+
+	// Use the latest environment, including changes made by hooks.
+	hookCmd := l.cmd
+	if hookCmd == nil {
+		hookCmd = &exec.Cmd{Env: env}
+	}
+
+	event := l.event
+	event.ExitCode = exitStatus
+	if err != nil {
+		event.Error = redact.String(err.Error())
+	}
+
+	// Run any "on_error" hooks if LTF failed before running the command.
+	if err != nil && !l.started {
+		if hookErr := l.hooks.Run(event.With("on_error"), hookCmd); hookErr != nil {
+			redact.Fprintf(os.Stderr, "%s: error from hook: %s\n", args.Bin, hookErr)
+		}
+	}
+
+	// Always run any "finally" hooks. If they fail, LTF fails too,
+	// unless it has already failed.
+	if hookErr := l.hooks.Run(event.With("finally"), hookCmd); hookErr != nil {
+		if err != nil {
+			redact.Fprintf(os.Stderr, "%s: error from hook: %s\n", args.Bin, hookErr)
+		} else {
+			if exitStatus == 0 {
+				exitStatus = 1
+			}
+			return cmd, exitStatus, fmt.Errorf("error from hook: %w", hookErr)
+		}
+	}
+
+	return cmd, exitStatus, err
+}
+
+func run(cwd string, args *arguments.Arguments, env ltf.Environ, l *lifecycle) (cmd *exec.Cmd, exitStatus int, err error) {
 	// Special mode to output environment variables after running a hook script.
 	// It outputs in JSON format to avoid issues with multi-line variables.
 	if args.EnvToJson {
@@ -40,18 +116,22 @@ func Run(cwd string, args *arguments.Arguments, env ltf.Environ) (cmd *exec.Cmd,
 		return nil, 0, nil
 	}
 
-	// Find and load the optional settings file to get hooks.
-	var hooks hook.Hooks
-	if s, err := settings.Load(cwd); err != nil {
-		return nil, 1, fmt.Errorf("error loading ltf settings: %w", err)
-	} else {
-		hooks = s.Hooks
+	// Special mode to print the JSON Schema for ltf.yaml files.
+	// This does not load the settings files, so it works even if they are invalid.
+	if args.Subcommand == "schema" && !args.Help {
+		schema, err := settings.Schema()
+		if err != nil {
+			return nil, 1, fmt.Errorf("error generating ltf schema: %w", err)
+		}
+		fmt.Print(string(schema))
+		return nil, 0, nil
 	}
 
 	// Skip some chdir and variables functionality for these commands.
 	skipMode := args.Help || args.Version || args.Subcommand == "" || args.Subcommand == "fmt"
 
 	// Determine the directories to use.
+	event := &hook.Event{Args: args, Vars: variable.Variables{}, Cwd: cwd}
 	dirs := []string{}
 	chdir := ""
 	if !skipMode {
@@ -59,10 +139,120 @@ func Run(cwd string, args *arguments.Arguments, env ltf.Environ) (cmd *exec.Cmd,
 		if err != nil {
 			return nil, 1, fmt.Errorf("error reading path: %w", err)
 		}
+		event.Cwd = cwd
 		dirs, chdir, err = filesystem.FindDirs(cwd, args)
 		if err != nil {
 			return nil, 1, fmt.Errorf("error finding directories: %w", err)
 		}
+		event.Chdir = chdir
+	}
+
+	// Load variables from all possible sources, so they can be used in
+	// ltf.hcl files. Errors are returned after loading the settings files,
+	// so that lifecycle hooks can run.
+	var varsErr error
+	if !skipMode {
+		vars, err := variable.Load(args, dirs, chdir)
+		if err != nil {
+			varsErr = fmt.Errorf("error loading variables: %w", err)
+		} else {
+			event.Vars = vars
+		}
+	}
+
+	// Find and load the optional settings files to get hooks and commands.
+	ctx, err := event.EvalContext(env)
+	if err != nil {
+		return nil, 1, fmt.Errorf("error loading ltf settings: %w", err)
+	}
+	if skipMode {
+		// These commands do not use the directories or variables,
+		// but ltf.hcl files can, so load them only for the settings files.
+		// Errors are ignored because these commands can run anywhere.
+		if abs, err := filepath.Abs(cwd); err == nil {
+			if skipCtx, err := environmentContext(args, env)(abs); err == nil {
+				ctx = skipCtx
+			}
+		}
+	}
+	s, err := settings.Load(cwd, ctx)
+	if err != nil {
+		if varsErr != nil {
+			// The settings files may have failed because of the variables.
+			return nil, 1, varsErr
+		}
+		return nil, 1, fmt.Errorf("error loading ltf settings: %w", err)
+	}
+	hooks := s.Hooks
+	commands := s.Commands
+	timeouts := s.Timeouts
+	event.Environment = s.Environment
+
+	// Special mode to show the merged settings and where they came from.
+	if args.Subcommand == "settings" && !args.Help {
+		if err := s.Print(os.Stdout); err != nil {
+			return nil, 1, fmt.Errorf("error printing ltf settings: %w", err)
+		}
+		return nil, 0, nil
+	}
+
+	// Special mode to show the hooks, and which hooks match a simulated command.
+	if args.Subcommand == "hooks" && !args.Help {
+		command := customArgs(args)
+		asJSON := len(command) > 0 && command[0] == "-json"
+		if asJSON {
+			command = command[1:]
+		}
+		if err := s.PrintHooks(os.Stdout, command, env, asJSON); err != nil {
+			return nil, 1, fmt.Errorf("error printing ltf hooks: %w", err)
+		}
+		return nil, 0, nil
+	}
+
+	// Special mode to list environment directories and their metadata.
+	if args.Subcommand == "environments" && !args.Help {
+		if err := settings.PrintEnvironments(os.Stdout, chdir, environmentContext(args, env)); err != nil {
+			return nil, 1, fmt.Errorf("error listing environments: %w", err)
+		}
+		return nil, 0, nil
+	}
+
+	// Run lifecycle hooks after this point, even if LTF fails.
+	l.hooks = hooks
+	l.event = event
+
+	// Check if the subcommand is a custom command from the settings file.
+	// Custom commands run a script instead of Terraform.
+	var custom *command.Command
+	if args.Subcommand != "" && !args.Help && !args.Version {
+		custom = commands[args.Subcommand]
+	}
+
+	// Add default arguments from the settings files to TF_CLI_ARGS_name,
+	// in the same way as the backend configuration below, and parse the
+	// arguments again so that variables and hooks use the combined arguments.
+	if defaultArgs := s.Args[args.Subcommand]; len(defaultArgs) > 0 && custom == nil {
+		name := "TF_CLI_ARGS_" + args.Subcommand
+		newEnvValue := arguments.Quote(defaultArgs)
+
+		// Append the old value at the end so it takes precedence
+		// over the values from the settings files.
+		if oldArgs := env.GetValue(name); oldArgs != "" {
+			newEnvValue += " " + oldArgs
+		}
+		env = env.SetValue(name, newEnvValue)
+		redact.Fprintf(os.Stderr, "+ %s=%s\n", name, newEnvValue)
+
+		newArgs, err := arguments.New(args.Args, env)
+		if err != nil {
+			return nil, 1, fmt.Errorf("error parsing arguments: %w", err)
+		}
+		*args = *newArgs
+		if varsErr == nil && !skipMode {
+			if _, err := event.Vars.LoadArgs(args); err != nil {
+				return nil, 1, fmt.Errorf("error loading variables: %w", err)
+			}
+		}
 	}
 
 	// Set the data directory to the current directory.
@@ -76,44 +266,120 @@ func Run(cwd string, args *arguments.Arguments, env ltf.Environ) (cmd *exec.Cmd,
 		fmt.Fprintf(os.Stderr, "+ TF_DATA_DIR=%s\n", dataDir)
 	}
 
-	// Load variables from all possible sources.
-	vars := variable.Variables{}
-	if !skipMode {
-		vars, err = variable.Load(args, dirs, chdir)
-		if err != nil {
-			return nil, 1, fmt.Errorf("error loading variables: %w", err)
+	// Special mode to remove cached hook results for the current directory.
+	if args.Subcommand == "cache" && !args.Help {
+		// Built-in commands do not run hooks.
+		l.hooks = nil
+
+		if rest := customArgs(args); len(rest) != 1 || rest[0] != "clear" {
+			return nil, 1, fmt.Errorf("usage: %s cache clear", args.Bin)
+		}
+		dataDir := event.DataDir(env)
+		if err := hook.ClearCache(dataDir); err != nil {
+			return nil, 1, fmt.Errorf("error clearing cache: %w", err)
 		}
-		for _, v := range vars {
-			env = env.SetValue("TF_VAR_"+v.Name, v.StringValue)
-			if v.StringValue != "" {
-				v.Print()
+		fmt.Fprintf(os.Stderr, "# removed %s\n", hook.CacheDir(dataDir))
+		return nil, 0, nil
+	}
+
+	// Use the Terraform or OpenTofu binary from the environment or settings files.
+	// Set it in the environment as LTF_BINARY so hooks and custom commands can
+	// use it too. This is separate from LTF_TERRAFORM_BINARY so that nested
+	// LTF commands find their own binary unless the user has chosen one.
+	// Custom commands might not run Terraform, so they only use versions that
+	// are already installed, and they still run if no version matches.
+	binary := env.GetValue("LTF_TERRAFORM_BINARY")
+	if binary == "" {
+		binary = s.Binary
+	}
+	if binary == "" && s.Versions != nil {
+		binary, err = findBinary(s.Versions, chdir, cwd, custom == nil)
+		if err != nil {
+			if custom == nil {
+				return nil, 1, fmt.Errorf("error finding terraform binary: %w", err)
 			}
+			fmt.Fprintf(os.Stderr, "# %s\n", err)
+			binary = ""
 		}
+	} else if binary == "" {
+		binary = "terraform"
+	}
+	if binary != "" {
+		env = env.SetValue("LTF_BINARY", binary)
 	}
 
-	// Build the Terraform command to run.
-	cmd = exec.Command("terraform")
+	// Use the variables loaded above.
+	if varsErr != nil {
+		return nil, 1, varsErr
+	}
+	vars := event.Vars
+	for _, v := range vars {
+		env = env.SetValue("TF_VAR_"+v.Name, v.StringValue)
+		if v.StringValue != "" {
+			v.Print()
+		}
+	}
+
+	// Build the Terraform command to run, or the script for a custom command.
+	if custom != nil {
+		cmd = custom.Cmd(nil)
+		cmd.Dir = cwd
+	} else {
+		cmd = exec.Command(binary)
+	}
+	var cmdString string
+	cmd.Args, cmdString, err = commandArgs(args, custom, binary, skipMode, cwd, chdir)
+	if err != nil {
+		return nil, 0, err
+	}
 	cmd.Env = env
+	l.cmd = cmd
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
-	// Make Terraform change to the configuration directory
-	// using the -chdir argument.
-	if !skipMode && args.Chdir == "" && chdir != cwd {
-		chdirFromCwd, err := filepath.Rel(cwd, chdir)
+	// Run any "before" hooks. A hook can request that Terraform is skipped,
+	// in which case LTF exits without running Terraform or any other hooks
+	// apart from "finally" hooks.
+	// Hooks can also change the arguments, which updates args in place.
+	originalArgs := *args
+	if err := hooks.Run(event.With("before"), cmd); err != nil {
+		var skip *hook.SkipError
+		if errors.As(err, &skip) {
+			fmt.Fprintf(os.Stderr, "# %s\n", skip)
+			return cmd, skip.ExitStatus, nil
+		}
+		if errors.Is(err, process.ErrTimeout) {
+			// Treat a hook timing out like Terraform failing,
+			// so "failed" hooks can clean up.
+			event.ExitCode = process.TimeoutExitStatus
+			if failedErr := hooks.Run(event.With("failed"), cmd); failedErr != nil {
+				redact.Fprintf(os.Stderr, "%s: error from hook: %s\n", args.Bin, failedErr)
+			}
+			return cmd, process.TimeoutExitStatus, fmt.Errorf("error from hook: %w", err)
+		}
+		return nil, 1, fmt.Errorf("error from hook: %w", err)
+	}
+
+	// Hooks can change the command line arguments, so build the command
+	// again with the final arguments. The subcommand and -chdir option
+	// cannot be changed because the directories and variables depend on them.
+	if !reflect.DeepEqual(args.Args, originalArgs.Args) {
+		if args.Subcommand != originalArgs.Subcommand || args.Chdir != originalArgs.Chdir {
+			return nil, 1, fmt.Errorf("error from hook: hooks cannot change the subcommand or -chdir option")
+		}
+		cmd.Args, cmdString, err = commandArgs(args, custom, binary, skipMode, cwd, chdir)
 		if err != nil {
 			return nil, 0, err
 		}
-		cmd.Args = append(cmd.Args, "-chdir="+chdirFromCwd)
 	}
 
-	// Pass all remaining command line arguments to Terraform.
-	cmd.Args = append(cmd.Args, args.Args[1:]...)
-
-	// Use backend configuration files.
-	if !skipMode && args.Subcommand == "init" {
-		backend, err := backend.LoadConfiguration(dirs, chdir, vars)
+	// Use backend configuration files. Custom commands get them too,
+	// so their scripts can run "terraform init" with the same backend.
+	// This happens after the "before" hooks so that *.tfbackend files
+	// can use variables set by hooks.
+	if !skipMode && (args.Subcommand == "init" || custom != nil) {
+		backend, err := backend.LoadConfiguration(dirs, chdir, vars, event.Environment)
 		if err != nil {
 			return nil, 1, err
 		}
@@ -126,6 +392,7 @@ func Run(cwd string, args *arguments.Arguments, env ltf.Environ) (cmd *exec.Cmd,
 
 			// Append the oldArgs TF_CLI_ARGS_init at the end so they take precedence
 			// over the values generated by LTF.
+			env := ltf.Environ(cmd.Env)
 			oldArgs := env.GetValue("TF_CLI_ARGS_init")
 			if oldArgs != "" {
 				initArgs = append(initArgs, oldArgs)
@@ -133,35 +400,62 @@ func Run(cwd string, args *arguments.Arguments, env ltf.Environ) (cmd *exec.Cmd,
 
 			// Set the new environment variable value.
 			newEnvValue := strings.Join(initArgs, " ")
-			env = env.SetValue("TF_CLI_ARGS_init", newEnvValue)
-			cmd.Env = env
-			fmt.Fprintf(os.Stderr, "+ TF_CLI_ARGS_init=%s\n", newEnvValue)
+			cmd.Env = env.SetValue("TF_CLI_ARGS_init", newEnvValue)
+			redact.Fprintf(os.Stderr, "+ TF_CLI_ARGS_init=%s\n", newEnvValue)
 		}
 	}
 
-	// Run any "before" hooks.
-	if err := hooks.Run("before", cmd, args, vars); err != nil {
-		return nil, 1, fmt.Errorf("error from hook: %w", err)
-	}
-
 	// Special cases to print messages before Terraform runs.
 	if args.Help {
 		fmt.Println(helpMessage)
 		fmt.Println("")
+		if len(commands) > 0 {
+			fmt.Println("Custom commands:")
+			for _, name := range commands.Names() {
+				fmt.Printf("  %-21s %s\n", name, commands[name].Description)
+			}
+			fmt.Println("")
+		}
 	} else if args.Version {
 		fmt.Printf("LTF %s\n\n", getVersion())
 	}
 
-	// Run the Terraform command.
+	// Run the Terraform command or custom command.
+	// The command is killed if it runs for longer than
+	// the timeout configured for the subcommand.
 	exitCode := 0
-	cmdString := strings.Join(cmd.Args, " ")
-	if v := env.GetValue("LTF_TEST_MODE"); v != "" {
-		fmt.Fprintf(os.Stderr, "# LTF_TEST_MODE=%s skipped %s\n", v, cmdString)
+	var timeoutErr error
+	if v := env.GetValue("LTF_TEST_MODE"); v != "" && custom == nil {
+		l.started = true
+		redact.Fprintf(os.Stderr, "# LTF_TEST_MODE=%s skipped %s\n", v, cmdString)
 	} else {
-		fmt.Fprintf(os.Stderr, "# %s\n", cmdString)
-		if err := cmd.Run(); err != nil {
+		redact.Fprintf(os.Stderr, "# %s\n", cmdString)
+
+		// Pass the output through any output filter hooks.
+		stdout, stderr, waitFilters, err := hooks.StartFilters(event.With("output"), cmd.Env, os.Stdout, os.Stderr)
+		if err != nil {
+			return nil, 1, fmt.Errorf("error from hook: %w", err)
+		}
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+
+		start := time.Now()
+		l.started = true
+		err = process.Run(cmd, timeouts[args.Subcommand])
+		event.Duration = time.Since(start)
+
+		// Output filters do not affect the exit code,
+		// so only show a warning if they fail.
+		if filterErr := waitFilters(); filterErr != nil {
+			redact.Fprintf(os.Stderr, "%s: warning: %s\n", args.Bin, filterErr)
+		}
+
+		if err != nil {
 			if exitErr, isExitError := err.(*exec.ExitError); isExitError {
 				exitCode = exitErr.ExitCode()
+			} else if errors.Is(err, process.ErrTimeout) {
+				exitCode = process.TimeoutExitStatus
+				timeoutErr = fmt.Errorf("error running command: %w", err)
 			} else {
 				return nil, 1, fmt.Errorf("error running command: %w", err)
 			}
@@ -169,13 +463,98 @@ func Run(cwd string, args *arguments.Arguments, env ltf.Environ) (cmd *exec.Cmd,
 	}
 
 	// Run any "after" or "failed" hooks.
+	event.ExitCode = exitCode
 	when := "after"
 	if exitCode != 0 {
 		when = "failed"
 	}
-	if err = hooks.Run(when, cmd, args, vars); err != nil {
+	if err = hooks.Run(event.With(when), cmd); err != nil {
+		if errors.Is(err, process.ErrTimeout) {
+			return nil, process.TimeoutExitStatus, fmt.Errorf("error from hook: %w", err)
+		}
 		return nil, 1, fmt.Errorf("error from hook: %w", err)
 	}
 
-	return cmd, exitCode, nil
+	return cmd, exitCode, timeoutErr
+}
+
+// environmentContext returns a function that creates the EvalContext
+// for ltf.hcl files in an environment directory, with the variables
+// that would be used when running LTF in that directory.
+func environmentContext(args *arguments.Arguments, env ltf.Environ) func(dir string) (*hcl.EvalContext, error) {
+	return func(dir string) (*hcl.EvalContext, error) {
+		dirs, chdir, err := filesystem.FindDirs(dir, args)
+		if err != nil {
+			return nil, err
+		}
+		vars, err := variable.Load(args, dirs, chdir)
+		if err != nil {
+			return nil, fmt.Errorf("error loading variables for %s: %w", dir, err)
+		}
+		event := hook.Event{Args: args, Vars: vars, Cwd: dir, Chdir: chdir}
+		return event.EvalContext(env)
+	}
+}
+
+// findBinary returns the path of a Terraform binary that matches the
+// required_version constraints of the configuration directory. If install
+// is true and no installed version matches, it installs one from the mirror.
+func findBinary(v *versions.Versions, chdir string, cwd string, install bool) (string, error) {
+	dir := chdir
+	if dir == "" {
+		dir = cwd
+	}
+	module, diags := filesystem.LoadModule(dir)
+	if err := diags.Err(); err != nil {
+		return "", err
+	}
+
+	binary, err := v.Find(module.RequiredCore)
+	var constraintErr *versions.ConstraintError
+	if errors.As(err, &constraintErr) && v.Mirror != "" && install {
+		fmt.Fprintf(os.Stderr, "# %s\n", err)
+		var installed string
+		binary, installed, err = v.Install(module.RequiredCore)
+		if err == nil {
+			fmt.Fprintf(os.Stderr, "# installed Terraform %s from %s\n", installed, v.Mirror)
+		}
+	}
+	return binary, err
+}
+
+// commandArgs returns the arguments for the Terraform command or custom command,
+// along with a string to show the command to the user.
+func commandArgs(args *arguments.Arguments, custom *command.Command, binary string, skipMode bool, cwd string, chdir string) ([]string, string, error) {
+	if custom != nil {
+		extraArgs := customArgs(args)
+		cmdString := strings.Join(append([]string{"ltf", custom.Name}, extraArgs...), " ")
+		return custom.Cmd(extraArgs).Args, cmdString, nil
+	}
+
+	cmdArgs := []string{binary}
+
+	// Make Terraform change to the configuration directory
+	// using the -chdir argument.
+	if !skipMode && args.Chdir == "" && chdir != cwd {
+		chdirFromCwd, err := filepath.Rel(cwd, chdir)
+		if err != nil {
+			return nil, "", err
+		}
+		cmdArgs = append(cmdArgs, "-chdir="+chdirFromCwd)
+	}
+
+	// Pass all remaining command line arguments to Terraform.
+	cmdArgs = append(cmdArgs, args.Args[1:]...)
+	return cmdArgs, strings.Join(cmdArgs, " "), nil
+}
+
+// customArgs returns the command line arguments after the subcommand,
+// to be passed into the script of a custom command.
+func customArgs(args *arguments.Arguments) []string {
+	for i, arg := range args.Args[1:] {
+		if arg == args.Subcommand {
+			return args.Args[i+2:]
+		}
+	}
+	return []string{}
 }
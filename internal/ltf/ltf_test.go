@@ -39,6 +39,26 @@ type AssertConfig struct {
 	Cmd      string            `hcl:"cmd,optional"`
 	Env      map[string]string `hcl:"env,optional"`
 	ExitCode int               `hcl:"exit,optional"`
+	Error    string            `hcl:"error,optional"`
+	Files    map[string]string `hcl:"files,optional"`
+}
+
+func TestMain(m *testing.M) {
+	// Hook scripts run the current program with the -env-to-json flag
+	// to output their environment variables. The current program is
+	// this test binary, so handle that flag here.
+	if len(os.Args) > 1 && os.Args[1] == "-env-to-json" {
+		env := ltf.NewEnviron(os.Environ()...)
+		args, err := arguments.New(os.Args, env)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if _, _, err := Run("", args, env); err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
 }
 
 func TestSuite(t *testing.T) {
@@ -86,7 +106,12 @@ func runTestCase(t *testing.T, arrange ArrangeConfig, act ActConfig, assert Asse
 	args, err := arguments.New(strings.Split(act.Cmd, " "), env)
 	is.NoErr(err) // error parsing arguments
 	cmd, exitCode, err := Run(cwd, args, env)
-	is.NoErr(err)
+	if assert.Error != "" {
+		is.True(err != nil)                                  // ltf did not return an error
+		is.True(strings.Contains(err.Error(), assert.Error)) // ltf did not return the expected error
+	} else {
+		is.NoErr(err)
+	}
 
 	// Assert
 
@@ -106,4 +131,13 @@ func runTestCase(t *testing.T, arrange ArrangeConfig, act ActConfig, assert Asse
 			})
 		}
 	}
+
+	for fileName, expected := range assert.Files {
+		t.Run(fileName, func(t *testing.T) {
+			is := is.New(t)
+			actual, err := ioutil.ReadFile(path.Join(tempDir, fileName))
+			is.NoErr(err)                      // error reading file
+			is.Equal(string(actual), expected) // ltf did not create the expected file
+		})
+	}
 }
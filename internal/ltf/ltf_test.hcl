@@ -109,3 +109,684 @@ arrange "variables" {
     }
   }
 }
+
+arrange "skip" {
+  files = {
+    "main.tf"  = ""
+    "ltf.yaml" = <<-EOF
+      hooks:
+        a skip:
+          before:
+            - terraform example
+          script: |
+            export TF_VAR_x=1
+            ltf_skip_terraform 3
+        b not reached:
+          before:
+            - terraform example
+          script: export TF_VAR_y=1
+        c not reached:
+          failed:
+            - terraform example
+          script: export TF_VAR_z=1
+    EOF
+  }
+
+  act "example" {
+    cmd = "ltf example"
+  }
+
+  assert "skipped" {
+    cmd  = "terraform example"
+    exit = 3
+    env  = {
+      LTF_SKIP_TERRAFORM = ""
+      TF_VAR_x           = "1"
+      TF_VAR_y           = ""
+      TF_VAR_z           = ""
+    }
+  }
+}
+
+arrange "commands" {
+  files = {
+    "main.tf"             = ""
+    "dev/dev.auto.tfvars" = "x = 1"
+    "dev/dev.tfbackend"   = "path = \"dev/dev.tfbackend\""
+    "ltf.yaml"            = <<-EOF
+      commands:
+        bootstrap:
+          description: Create backend resources
+          script: |
+            set -euo pipefail
+            test "$TF_VAR_x" = 1
+            test "$TF_DATA_DIR" = dev/.terraform
+            test "$TF_CLI_ARGS_init" = -backend-config=path=dev/dev.tfbackend
+            test "$(basename "$PWD")" = dev
+            test "$*" = "one two"
+            exit 5
+    EOF
+  }
+
+  act "bootstrap" {
+    cwd = "dev"
+    cmd = "ltf bootstrap one two"
+  }
+
+  assert "script ran with the resolved environment" {
+    exit = 5
+    env  = {
+      TF_DATA_DIR = "dev/.terraform"
+      TF_VAR_x    = "1"
+    }
+  }
+}
+
+arrange "builtin command names" {
+  files = {
+    "main.tf"  = ""
+    "ltf.yaml" = <<-EOF
+      commands:
+        hooks:
+          script: exit 5
+    EOF
+  }
+
+  act "hooks" {
+    cmd = "ltf hooks"
+    assert "built-in command names are rejected" {
+      exit  = 1
+      error = "invalid command name \"hooks\": it is used by a built-in LTF command"
+    }
+  }
+}
+
+arrange "init hooks" {
+  files = {
+    "main.tf"           = ""
+    "dev/dev.tfbackend" = "path = \"dev/dev.tfbackend\""
+    "ltf.yaml"          = <<-EOF
+      hooks:
+        seen:
+          before:
+            - terraform init
+          script: export TF_VAR_seen="$${TF_CLI_ARGS_init-none}"
+    EOF
+  }
+
+  act "init" {
+    cwd = "dev"
+    cmd = "ltf init"
+    assert "before hooks run before the backend configuration is generated" {
+      env = {
+        TF_CLI_ARGS_init = "-backend-config=path=dev/dev.tfbackend"
+        TF_VAR_seen      = "none"
+      }
+    }
+  }
+}
+
+arrange "vars file" {
+  files = {
+    "main.tf"           = "variable \"zones\" { type = list(string) }"
+    "terraform.tfvars"  = "frozen = \"original\""
+    "dev/dev.tfbackend" = "bucket = var.backend.bucket"
+    "dev/ltf.yaml"      = <<-EOF
+      hooks:
+        backend:
+          before:
+            - terraform init
+          shell: sh
+          script: |
+            cat > "$LTF_VARS_FILE" <<END
+            backend = { bucket = "dev-state" }
+            zones   = ["a", "b"]
+            END
+    EOF
+    "live/ltf.yaml"     = <<-EOF
+      hooks:
+        frozen:
+          before:
+            - terraform init
+          command: [sh, -c, 'echo "{\"frozen\": \"changed\"}" > "$LTF_VARS_FILE"']
+    EOF
+  }
+
+  act "init" {
+    cwd = "dev"
+    cmd = "ltf init"
+    assert "typed variables are used for the backend" {
+      env = {
+        TF_CLI_ARGS_init = "-backend-config=bucket=dev-state"
+        TF_VAR_backend   = "{\"bucket\":\"dev-state\"}"
+        TF_VAR_zones     = "[\"a\",\"b\"]"
+      }
+    }
+  }
+
+  act "frozen" {
+    cwd = "live"
+    cmd = "ltf init"
+    assert "frozen variables cannot be changed" {
+      exit  = 1
+      error = "cannot change frozen variable frozen"
+    }
+  }
+}
+
+arrange "args file" {
+  files = {
+    "main.tf"       = "variable \"x\" {}"
+    "dev/ltf.yaml"  = <<-EOF
+      hooks:
+        ci args:
+          before:
+            - terraform plan
+          script: |
+            echo -parallelism=5 >> "$LTF_ARGS_FILE"
+            echo -var=x=ci >> "$LTF_ARGS_FILE"
+        sees final args:
+          before:
+            - terraform plan -parallelism
+          depends_on: [ci args]
+          script: export TF_VAR_seen=$LTF_ARGS
+    EOF
+    "live/ltf.yaml" = <<-EOF
+      hooks:
+        change subcommand:
+          before:
+            - terraform plan
+          command: [sh, -c, 'echo apply > "$LTF_ARGS_FILE"']
+    EOF
+  }
+
+  act "plan" {
+    cwd = "dev"
+    cmd = "ltf plan"
+    assert "arguments are changed" {
+      cmd = "terraform -chdir=.. plan -parallelism=5 -var=x=ci"
+      env = {
+        TF_VAR_x    = "ci"
+        TF_VAR_seen = "plan -parallelism=5 -var=x=ci"
+      }
+    }
+  }
+
+  act "subcommand" {
+    cwd = "live"
+    cmd = "ltf plan"
+    assert "subcommand cannot be changed" {
+      exit  = 1
+      error = "hooks cannot change the subcommand"
+    }
+  }
+}
+
+arrange "cache" {
+  files = {
+    "main.tf"                               = ""
+    "dev/.terraform/ltf/cache/example.json" = "{}"
+  }
+
+  act "clear" {
+    cwd = "dev"
+    cmd = "ltf cache clear"
+    assert "cache is cleared" {
+      exit = 0
+    }
+  }
+
+  act "usage" {
+    cwd = "dev"
+    cmd = "ltf cache"
+    assert "usage is shown" {
+      exit  = 1
+      error = "usage: ltf cache clear"
+    }
+  }
+}
+
+arrange "lifecycle" {
+  files = {
+    "main.tf"                 = ""
+    "broken/terraform.tfvars" = "x = "
+    "ltf.yaml"                = <<-EOF
+      hooks:
+        cleanup:
+          finally:
+            - terraform
+          script: echo "$LTF_EXIT_CODE $LTF_ERROR" > "$LTF_CONFIG_DIR/finally.log"
+        error:
+          on_error:
+            - terraform
+          script: echo "$LTF_EXIT_CODE" > "$LTF_CONFIG_DIR/on_error.log"
+        skip:
+          before:
+            - terraform apply
+          script: ltf_skip_terraform 3
+    EOF
+  }
+
+  act "plan" {
+    cmd = "ltf plan"
+    assert "finally hooks run" {
+      files = {
+        "finally.log" = "0 \n"
+      }
+    }
+  }
+
+  act "skip" {
+    cmd = "ltf apply"
+    assert "finally hooks run after skipping terraform" {
+      exit  = 3
+      files = {
+        "finally.log" = "3 \n"
+      }
+    }
+  }
+
+  act "error" {
+    cwd = "broken"
+    cmd = "ltf plan"
+    assert "on_error and finally hooks run when ltf fails" {
+      exit  = 1
+      error = "error loading variables"
+      files = {
+        "on_error.log" = "1\n"
+      }
+    }
+  }
+}
+
+arrange "isolation" {
+  files = {
+    "main.tf"  = ""
+    "ltf.yaml" = <<-EOF
+      hooks:
+        a credentials:
+          before:
+            - terraform
+          env:
+            ROLE: deploy
+          export:
+            - AWS_*
+          script: |
+            export AWS_ROLE="$ROLE"
+            export TEMP_DIR=/tmp/credentials
+            unset KEEP
+        b isolated:
+          before:
+            - terraform
+          inherit_env: false
+          script: export TF_VAR_saw_role="$${AWS_ROLE:-none}"
+    EOF
+  }
+
+  act "plan" {
+    cmd = "ltf plan"
+    env = {
+      KEEP = "yes"
+    }
+    assert "only exported variables are passed on" {
+      env = {
+        AWS_ROLE        = "deploy"
+        ROLE            = ""
+        TEMP_DIR        = ""
+        KEEP            = "yes"
+        TF_VAR_saw_role = "none"
+      }
+    }
+  }
+}
+
+arrange "hcl settings" {
+  files = {
+    "main.tf"               = ""
+    "dev/terraform.tfvars"  = "env = \"dev\""
+    "live/terraform.tfvars" = "env = \"live\""
+    "ltf.hcl"               = <<-EOF
+      hook "tier" {
+        before = var.env == "live" ? ["terraform apply"] : ["terraform"]
+        script = "export TF_VAR_tier=$${path.env}"
+      }
+    EOF
+  }
+
+  act "dev" {
+    cwd = "dev"
+    cmd = "ltf plan"
+    assert "hook matches every command" {
+      env = {
+        TF_VAR_tier = "dev"
+      }
+    }
+  }
+
+  act "live" {
+    cwd = "live"
+    cmd = "ltf plan"
+    assert "hook only matches apply" {
+      env = {
+        TF_VAR_tier = ""
+      }
+    }
+  }
+
+  act "help" {
+    cwd = "dev"
+    cmd = "ltf -help"
+    assert "variables can be used with help" {
+      exit = 0
+    }
+  }
+
+  act "fmt" {
+    cwd = "dev"
+    cmd = "ltf fmt"
+    assert "variables can be used with fmt" {
+      exit = 0
+      cmd  = "terraform fmt"
+      env  = {
+        TF_VAR_env = ""
+      }
+    }
+  }
+}
+
+arrange "opentofu" {
+  files = {
+    "main.tofu" = "variable \"x\" { default = \"tofu\" }"
+    "dev/.keep" = ""
+    "ltf.yaml"  = "binary: tofu"
+  }
+
+  act "plan" {
+    cwd = "dev"
+    cmd = "ltf plan"
+    assert "tofu runs" {
+      cmd = "tofu -chdir=.. plan"
+      env = {
+        LTF_BINARY  = "tofu"
+        TF_DATA_DIR = "dev/.terraform"
+        TF_VAR_x    = "tofu"
+      }
+    }
+  }
+
+  act "env" {
+    cwd = "dev"
+    cmd = "ltf plan"
+    env = {
+      LTF_TERRAFORM_BINARY = "/opt/terraform"
+    }
+    assert "environment variable takes precedence" {
+      cmd = "/opt/terraform -chdir=.. plan"
+    }
+  }
+
+  act "nested" {
+    cwd = "dev"
+    cmd = "ltf plan"
+    env = {
+      LTF_BINARY = "/opt/terraform"
+    }
+    assert "binary from a parent ltf command is not used" {
+      cmd = "tofu -chdir=.. plan"
+    }
+  }
+}
+
+arrange "required version" {
+  files = {
+    "versions/1.5.0/terraform" = ""
+    "versions/1.5.7/terraform" = ""
+    "versions/1.6.0/terraform" = ""
+    "current/main.tf"          = "terraform { required_version = \"~> 1.5.0\" }"
+    "old/main.tf"              = "terraform { required_version = \"~> 1.3.0\" }"
+    "ltf.yaml"                 = <<-EOF
+      versions:
+        dir: versions
+      hooks:
+        binary:
+          before:
+            - terraform
+          script: export TF_VAR_binary="$${LTF_BINARY#$(dirname "$LTF_CONFIG_DIR")/}"
+      commands:
+        check:
+          script: test -z "$${LTF_BINARY-}"
+    EOF
+  }
+
+  act "current" {
+    cwd = "current"
+    cmd = "ltf plan"
+    assert "newest matching version is used" {
+      env = {
+        TF_VAR_binary = "versions/1.5.7/terraform"
+      }
+    }
+  }
+
+  act "old" {
+    cwd = "old"
+    cmd = "ltf plan"
+    assert "no matching version" {
+      exit  = 1
+      error = "no Terraform version matches required_version \"~> 1.3.0\""
+    }
+  }
+
+  act "old cache" {
+    cwd = "old"
+    cmd = "ltf cache clear"
+    assert "built-in commands do not need a binary" {
+      exit = 0
+    }
+  }
+
+  act "old custom command" {
+    cwd = "old"
+    cmd = "ltf check"
+    assert "custom commands run without a binary" {
+      exit = 0
+    }
+  }
+}
+
+arrange "default args" {
+  files = {
+    "main.tf"      = "variable \"x\" {}"
+    "ltf.yaml"     = <<-EOF
+      args:
+        plan: [-lock-timeout=5m]
+        apply: [-parallelism=20]
+      hooks:
+        sees default args:
+          before:
+            - terraform plan -lock-timeout
+          script: export TF_VAR_locked=yes
+    EOF
+    "dev/ltf.yaml" = <<-EOF
+      args:
+        plan: [-var=x=dev]
+    EOF
+  }
+
+  act "plan" {
+    cwd = "dev"
+    cmd = "ltf plan"
+    env = {
+      TF_CLI_ARGS_plan = "-lock-timeout=1m"
+    }
+    assert "default args are added" {
+      cmd = "terraform -chdir=.. plan"
+      env = {
+        TF_CLI_ARGS_plan  = "-lock-timeout=5m -var=x=dev -lock-timeout=1m"
+        TF_CLI_ARGS_apply = ""
+        TF_VAR_x          = "dev"
+        TF_VAR_locked     = "yes"
+      }
+    }
+  }
+
+  act "override" {
+    cwd = "dev"
+    cmd = "ltf plan -var=x=cli"
+    assert "command line args take precedence" {
+      cmd = "terraform -chdir=.. plan -var=x=cli"
+      env = {
+        TF_VAR_x = "cli"
+      }
+    }
+  }
+}
+
+arrange "environment metadata" {
+  files = {
+    "main.tf"             = ""
+    "live/live.tfbackend" = "workspace = environment.name"
+    "live/ltf.yaml"       = <<-EOF
+      environment:
+        name: live-blue
+        tier: live
+        labels:
+          colour: blue
+        protected: true
+    EOF
+    "ltf.yaml"            = <<-EOF
+      environment:
+        owner: platform
+      hooks:
+        metadata:
+          before:
+            - terraform
+          script: export TF_VAR_meta="$LTF_ENV_NAME|$LTF_ENV_TIER|$LTF_ENV_OWNER|$LTF_ENV_LABELS|$LTF_ENV_PROTECTED"
+        protected:
+          before:
+            - terraform
+          if: environment.protected
+          script: export TF_VAR_protected=yes
+    EOF
+  }
+
+  act "init" {
+    cwd = "live"
+    cmd = "ltf init"
+    assert "metadata is used by hooks and backend files" {
+      env = {
+        TF_CLI_ARGS_init = "-backend-config=workspace=live-blue"
+        TF_VAR_meta      = "live-blue|live|platform|{\"colour\":\"blue\"}|true"
+        TF_VAR_protected = "yes"
+        LTF_ENV_NAME     = ""
+      }
+    }
+  }
+}
+
+arrange "conditions" {
+  files = {
+    "main.tf"               = ""
+    "dev/terraform.tfvars"  = "env = \"dev\""
+    "live/terraform.tfvars" = "env = \"live\""
+    "ltf.yaml"              = <<-EOF
+      hooks:
+        live only:
+          before:
+            - terraform plan
+          if: var.env == "live"
+          script: export TF_VAR_live=yes
+        dev directory only:
+          before:
+            - terraform plan
+          if: path.env == "dev"
+          script: export TF_VAR_dev=yes
+    EOF
+  }
+
+  act "dev" {
+    cwd = "dev"
+    cmd = "ltf plan"
+
+    assert "dev hook ran" {
+      env = {
+        TF_VAR_dev  = "yes"
+        TF_VAR_live = ""
+      }
+    }
+  }
+
+  act "live" {
+    cwd = "live"
+    cmd = "ltf plan"
+
+    assert "live hook ran" {
+      env = {
+        TF_VAR_dev  = ""
+        TF_VAR_live = "yes"
+      }
+    }
+  }
+}
+
+arrange "timeouts" {
+  files = {
+    "main.tf"  = ""
+    "ltf.yaml" = <<-EOF
+      hooks:
+        slow:
+          before:
+            - terraform plan
+          timeout: 100ms
+          script: sleep 30
+        cleanup:
+          failed:
+            - terraform plan
+          script: export TF_VAR_cleanup=yes
+    EOF
+  }
+
+  act "plan" {
+    cmd = "ltf plan"
+  }
+
+  assert "failed hooks ran after timeout" {
+    exit  = 124
+    error = "hook slow: timed out after 100ms"
+    env   = {
+      TF_VAR_cleanup = "yes"
+    }
+  }
+}
+
+arrange "hook context" {
+  files = {
+    "main.tf"   = ""
+    "dev/.keep" = ""
+    "ltf.yaml"  = <<-EOF
+      hooks:
+        context:
+          before:
+            - terraform
+          after:
+            - terraform
+          script: |
+            export TF_VAR_$${LTF_HOOK_NAME}_$${LTF_EXIT_CODE:-none}="$LTF_SUBCOMMAND|$LTF_ARGS|$LTF_ENV_DIR|$(basename "$LTF_DATA_DIR")|$LTF_DURATION_MS"
+    EOF
+  }
+
+  act "plan" {
+    cwd = "dev"
+    cmd = "ltf plan -out=tfplan"
+  }
+
+  assert "hooks received context" {
+    env = {
+      TF_VAR_context_none = "plan|plan -out=tfplan|dev|.terraform|"
+      TF_VAR_context_0    = "plan|plan -out=tfplan|dev|.terraform|0"
+      LTF_SUBCOMMAND      = ""
+      LTF_HOOK_NAME       = ""
+      LTF_EXIT_CODE       = ""
+    }
+  }
+}
@@ -0,0 +1,122 @@
+package process
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// TimeoutExitStatus is the exit status used by LTF when a process times out.
+// It is the same as the exit status used by the timeout command in GNU coreutils.
+const TimeoutExitStatus = 124
+
+// killGrace is how long to wait after asking a process group to stop
+// before killing it forcefully. This gives Terraform a chance to release
+// state locks and exit cleanly.
+const killGrace = 10 * time.Second
+
+// ErrTimeout is returned when a process was killed after reaching its timeout.
+var ErrTimeout = errors.New("timed out")
+
+// Process is a command that has been started with an optional timeout.
+type Process struct {
+	cmd     *exec.Cmd
+	timeout time.Duration
+	timer   *time.Timer
+	signals chan os.Signal
+	restore func()
+
+	mu       sync.Mutex
+	timedOut bool
+	exited   bool
+}
+
+// Start starts the command. If timeout is greater than zero, the command
+// is started in its own process group, and the whole process group is killed
+// if the command runs for longer than the timeout. Interrupt and terminate
+// signals received by LTF are forwarded to the process group in that case,
+// because they are not always delivered to it by the terminal. If the command
+// reads from the terminal, its process group is put in the foreground of the
+// terminal until it exits, so that it can prompt for input.
+func Start(cmd *exec.Cmd, timeout time.Duration) (*Process, error) {
+	p := &Process{cmd: cmd, timeout: timeout, restore: func() {}}
+
+	if timeout > 0 {
+		p.restore = setProcessGroup(cmd)
+	}
+
+	if err := cmd.Start(); err != nil {
+		p.restore()
+		return nil, err
+	}
+
+	if timeout > 0 {
+		p.signals = make(chan os.Signal, 1)
+		signal.Notify(p.signals, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			for sig := range p.signals {
+				signalProcessGroup(cmd, sig)
+			}
+		}()
+		p.timer = time.AfterFunc(timeout, p.kill)
+	}
+
+	return p, nil
+}
+
+// Wait waits for the command to exit. It returns an error wrapping
+// ErrTimeout if the command was killed after reaching its timeout.
+func (p *Process) Wait() error {
+	err := p.cmd.Wait()
+	p.restore()
+
+	p.mu.Lock()
+	p.exited = true
+	timedOut := p.timedOut
+	p.mu.Unlock()
+
+	if p.timer != nil {
+		p.timer.Stop()
+		signal.Stop(p.signals)
+		close(p.signals)
+	}
+
+	if timedOut {
+		return fmt.Errorf("%w after %s", ErrTimeout, p.timeout)
+	}
+	return err
+}
+
+// kill stops the process group, first by asking it to terminate,
+// and then forcefully if it has not exited after a grace period.
+func (p *Process) kill() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.exited {
+		return
+	}
+	p.timedOut = true
+	signalProcessGroup(p.cmd, syscall.SIGTERM)
+	time.AfterFunc(killGrace, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if !p.exited {
+			signalProcessGroup(p.cmd, syscall.SIGKILL)
+		}
+	})
+}
+
+// Run starts the command and waits for it to exit, using the same timeout
+// behaviour as Start and Wait.
+func Run(cmd *exec.Cmd, timeout time.Duration) error {
+	p, err := Start(cmd, timeout)
+	if err != nil {
+		return err
+	}
+	return p.Wait()
+}
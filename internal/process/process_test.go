@@ -0,0 +1,80 @@
+package process
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestRun(t *testing.T) {
+	t.Run("without timeout", func(t *testing.T) {
+		is := is.New(t)
+
+		err := Run(exec.Command("bash", "-c", "exit 0"), 0)
+
+		is.NoErr(err)
+	})
+
+	t.Run("finishes before timeout", func(t *testing.T) {
+		is := is.New(t)
+
+		err := Run(exec.Command("bash", "-c", "exit 3"), time.Minute)
+
+		var exitErr *exec.ExitError
+		is.True(errors.As(err, &exitErr)) // expected exit error
+		is.Equal(exitErr.ExitCode(), 3)
+	})
+
+	t.Run("kills process group after timeout", func(t *testing.T) {
+		is := is.New(t)
+
+		// Arrange
+
+		// The child process keeps running in the background,
+		// so it must be killed along with its parent.
+		cmd := exec.Command("bash", "-c", "sleep 30 & wait")
+
+		// Act
+
+		start := time.Now()
+		err := Run(cmd, 100*time.Millisecond)
+
+		// Assert
+
+		is.True(errors.Is(err, ErrTimeout)) // expected timeout error
+		is.True(time.Since(start) < 10*time.Second)
+	})
+	t.Run("reads input with timeout", func(t *testing.T) {
+		is := is.New(t)
+
+		// Arrange
+
+		// A pipe is a file but not a terminal,
+		// so the command should not be put in the foreground.
+		r, w, err := os.Pipe()
+		is.NoErr(err)
+		defer r.Close()
+		_, err = w.WriteString("yes\n")
+		is.NoErr(err)
+		w.Close()
+
+		stdout := &bytes.Buffer{}
+		cmd := exec.Command("bash", "-c", "read -r answer && echo \"$answer\"")
+		cmd.Stdin = r
+		cmd.Stdout = stdout
+
+		// Act
+
+		err = Run(cmd, time.Minute)
+
+		// Assert
+
+		is.NoErr(err)
+		is.Equal(stdout.String(), "yes\n")
+	})
+}
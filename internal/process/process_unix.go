@@ -0,0 +1,59 @@
+//go:build !windows
+// +build !windows
+
+package process
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+// setProcessGroup makes the command start in a new process group.
+// If the command reads from a terminal that LTF is in the foreground of,
+// the new process group is put in the foreground instead, so that the command
+// can still prompt for input. It returns a function to call after the command
+// has exited, which puts LTF back in the foreground.
+func setProcessGroup(cmd *exec.Cmd) (restore func()) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+
+	tty, ok := cmd.Stdin.(*os.File)
+	if !ok || !isForeground(tty) {
+		return func() {}
+	}
+	cmd.SysProcAttr.Foreground = true
+	cmd.SysProcAttr.Ctty = int(tty.Fd())
+	return func() {
+		setForeground(tty, syscall.Getpgrp())
+	}
+}
+
+// isForeground reports whether the file is a terminal
+// and LTF's process group is in the foreground of it.
+func isForeground(f *os.File) bool {
+	var pgrp int32
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), uintptr(syscall.TIOCGPGRP), uintptr(unsafe.Pointer(&pgrp)))
+	return errno == 0 && int(pgrp) == syscall.Getpgrp()
+}
+
+// setForeground puts the process group in the foreground of the terminal.
+// LTF is in a background process group at this point, so SIGTTOU is ignored
+// while doing this to stop the terminal from suspending LTF.
+func setForeground(f *os.File, pgrp int) {
+	signal.Ignore(syscall.SIGTTOU)
+	defer signal.Reset(syscall.SIGTTOU)
+	pgid := int32(pgrp)
+	_, _, _ = syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), uintptr(syscall.TIOCSPGRP), uintptr(unsafe.Pointer(&pgid)))
+}
+
+// signalProcessGroup sends a signal to the command's process group.
+func signalProcessGroup(cmd *exec.Cmd, sig os.Signal) {
+	if s, ok := sig.(syscall.Signal); ok {
+		_ = syscall.Kill(-cmd.Process.Pid, s)
+	}
+}
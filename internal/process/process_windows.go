@@ -0,0 +1,17 @@
+package process
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup does nothing on Windows.
+func setProcessGroup(cmd *exec.Cmd) (restore func()) {
+	return func() {}
+}
+
+// signalProcessGroup kills the command on Windows,
+// which does not support process groups in the same way.
+func signalProcessGroup(cmd *exec.Cmd, sig os.Signal) {
+	_ = cmd.Process.Kill()
+}
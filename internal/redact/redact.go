@@ -0,0 +1,56 @@
+// Package redact removes secret values from the output of LTF.
+package redact
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Placeholder replaces secret values in output.
+// It matches how Terraform shows sensitive values.
+const Placeholder = "(sensitive value)"
+
+var (
+	mu      sync.RWMutex
+	secrets = map[string]bool{}
+	sorted  []string
+)
+
+// Add registers a secret value to be redacted from output.
+// Empty values are ignored.
+func Add(value string) {
+	if value == "" {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if secrets[value] {
+		return
+	}
+	secrets[value] = true
+
+	// Replace longer values first, in case one secret contains another.
+	sorted = append(sorted, value)
+	sort.Slice(sorted, func(i, j int) bool {
+		return len(sorted[i]) > len(sorted[j])
+	})
+}
+
+// String returns s with any secret values replaced by the placeholder.
+func String(s string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, secret := range sorted {
+		s = strings.ReplaceAll(s, secret, Placeholder)
+	}
+	return s
+}
+
+// Fprintf formats according to a format specifier and writes to w,
+// with any secret values replaced by the placeholder.
+func Fprintf(w io.Writer, format string, a ...interface{}) (int, error) {
+	return io.WriteString(w, String(fmt.Sprintf(format, a...)))
+}
@@ -0,0 +1,30 @@
+package redact
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestRedact(t *testing.T) {
+	is := is.New(t)
+
+	// Arrange
+
+	Add("secret")
+	Add("secret-token")
+	Add("")
+
+	// Act
+
+	s := String("token=secret-token password=secret empty=")
+	var buf bytes.Buffer
+	_, err := Fprintf(&buf, "+ TF_VAR_%s=%s\n", "password", "secret")
+
+	// Assert
+
+	is.Equal(s, "token=(sensitive value) password=(sensitive value) empty=")
+	is.NoErr(err)
+	is.Equal(buf.String(), "+ TF_VAR_password=(sensitive value)\n")
+}
@@ -0,0 +1,186 @@
+package settings
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agext/levenshtein"
+	"gopkg.in/yaml.v3"
+)
+
+// Diagnostic is a problem found in a settings file.
+type Diagnostic struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (d Diagnostic) Error() string {
+	if d.Line == 0 {
+		return fmt.Sprintf("%s: %s", d.File, d.Message)
+	}
+	if d.Column == 0 {
+		return fmt.Sprintf("%s:%d: %s", d.File, d.Line, d.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", d.File, d.Line, d.Column, d.Message)
+}
+
+// Diagnostics holds all problems found in a settings file.
+type Diagnostics []Diagnostic
+
+func (d Diagnostics) Error() string {
+	messages := []string{}
+	for _, diag := range d {
+		messages = append(messages, diag.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+var syntaxErrorLine = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// syntaxError returns a Diagnostic for a YAML syntax error if it includes a line number.
+// YAML syntax errors do not include the column.
+func syntaxError(file string, err error) error {
+	if m := syntaxErrorLine.FindStringSubmatch(err.Error()); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		return Diagnostics{{File: file, Line: line, Message: m[2]}}
+	}
+	return fmt.Errorf("%s: %w", file, err)
+}
+
+// checkNode compares a YAML node with the Go type that it will be decoded into,
+// and returns diagnostics for unknown fields and values of the wrong type.
+// The path describes the node in messages, e.g. "hooks.login".
+func checkNode(file string, node *yaml.Node, t reflect.Type, path string) Diagnostics {
+	diags := Diagnostics{}
+	add := func(n *yaml.Node, format string, a ...interface{}) {
+		diags = append(diags, Diagnostic{File: file, Line: n.Line, Column: n.Column, Message: fmt.Sprintf(format, a...)})
+	}
+
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return diags
+		}
+		return checkNode(file, node.Content[0], t, path)
+	}
+	if node.Kind == yaml.AliasNode {
+		return checkNode(file, node.Alias, t, path)
+	}
+	if node.Tag == "!!null" {
+		return diags
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	describe := path
+	if describe == "" {
+		describe = "settings"
+	}
+
+	switch {
+	case t == durationType:
+		if node.Kind != yaml.ScalarNode {
+			add(node, "%s must be a duration, such as 30s or 10m", describe)
+		} else if _, err := time.ParseDuration(node.Value); err != nil || node.Tag == "!!int" {
+			add(node, "%s must be a duration, such as 30s or 10m, not %q", describe, node.Value)
+		}
+
+	case t.Kind() == reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			add(node, "%s must be a mapping", describe)
+			return diags
+		}
+		fields := map[string]yamlField{}
+		names := []string{}
+		for _, field := range yamlFields(t) {
+			fields[field.name] = field
+			names = append(names, field.name)
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			field, found := fields[key.Value]
+			if !found {
+				message := fmt.Sprintf("unknown field %q in %s", key.Value, describe)
+				if suggestion := suggest(key.Value, names); suggestion != "" {
+					message += fmt.Sprintf(", did you mean %q?", suggestion)
+				}
+				add(key, "%s", message)
+				continue
+			}
+			diags = append(diags, checkNode(file, value, field.typ, join(path, key.Value))...)
+		}
+
+	case t.Kind() == reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			add(node, "%s must be a mapping", describe)
+			return diags
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			diags = append(diags, checkNode(file, value, t.Elem(), join(path, key.Value))...)
+		}
+
+	case t.Kind() == reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			add(node, "%s must be a list", describe)
+			return diags
+		}
+		for i, item := range node.Content {
+			diags = append(diags, checkNode(file, item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+
+	case t.Kind() == reflect.Bool:
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!bool" {
+			add(node, "%s must be true or false", describe)
+		}
+
+	case t.Kind() == reflect.Int || t.Kind() == reflect.Int64:
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!int" {
+			add(node, "%s must be a whole number", describe)
+		}
+
+	case t.Kind() == reflect.Float64:
+		if node.Kind != yaml.ScalarNode || (node.Tag != "!!int" && node.Tag != "!!float") {
+			add(node, "%s must be a number", describe)
+		}
+
+	default:
+		if node.Kind != yaml.ScalarNode {
+			add(node, "%s must be a string", describe)
+		}
+	}
+
+	return diags
+}
+
+// suggest returns the name that is most similar to the given name,
+// or an empty string if none of the names are similar enough.
+func suggest(name string, names []string) string {
+	best := ""
+	bestDistance := 0
+	for _, candidate := range names {
+		distance := levenshtein.Distance(name, candidate, nil)
+		if best == "" || distance < bestDistance {
+			best = candidate
+			bestDistance = distance
+		}
+	}
+	if best == "" || bestDistance > 2+len(name)/4 || bestDistance >= len(name) {
+		return ""
+	}
+	return best
+}
+
+// join adds a key to a path used in messages.
+func join(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,106 @@
+package settings
+
+import (
+	"encoding/json"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/raymondbutcher/ltf/internal/filesystem"
+)
+
+// environmentInfo describes an environment directory for the output of "ltf environments".
+type environmentInfo struct {
+	Dir       string            `json:"dir"`
+	Source    string            `json:"source"`
+	Name      string            `json:"name"`
+	Tier      string            `json:"tier"`
+	Owner     string            `json:"owner"`
+	Labels    map[string]string `json:"labels"`
+	Protected bool              `json:"protected"`
+}
+
+// environmentsReport is the output of "ltf environments".
+type environmentsReport struct {
+	// Environments holds the environment directories, sorted by path.
+	Environments []environmentInfo `json:"environments"`
+}
+
+// PrintEnvironments finds directories inside root with settings files that
+// declare environment metadata with a name, and writes them in JSON format
+// along with the metadata inherited from parent directories. The context function
+// returns the EvalContext for ltf.hcl files in each directory.
+func PrintEnvironments(w io.Writer, root string, context func(dir string) (*hcl.EvalContext, error)) error {
+	report := environmentsReport{Environments: []environmentInfo{}}
+
+	err := filepath.WalkDir(root, func(dir string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		if dir != root && strings.HasPrefix(entry.Name(), ".") {
+			// Skip directories such as .git and .terraform.
+			return filepath.SkipDir
+		}
+
+		names, err := filesystem.ReadNames(dir)
+		if err != nil {
+			return err
+		}
+		if len(filesystem.MatchNames(names, "ltf.yaml"))+len(filesystem.MatchNames(names, "ltf.hcl")) == 0 {
+			return nil
+		}
+
+		ctx, err := context(dir)
+		if err != nil {
+			return err
+		}
+		s, err := Load(dir, ctx)
+		if err != nil {
+			return err
+		}
+
+		// Only list named environments in directories that declare metadata
+		// themselves, rather than parent directories with shared metadata
+		// or every directory inside an environment.
+		sources := strings.Split(s.Sources["environment"], ", ")
+		source := sources[len(sources)-1]
+		if s.Environment == nil || s.Environment.Name == "" || path.Dir(source) != dir {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, dir)
+		if err != nil {
+			return err
+		}
+		info := environmentInfo{
+			Dir:       rel,
+			Source:    source,
+			Name:      s.Environment.Name,
+			Tier:      s.Environment.Tier,
+			Owner:     s.Environment.Owner,
+			Labels:    s.Environment.Labels,
+			Protected: s.Environment.IsProtected(),
+		}
+		if info.Labels == nil {
+			info.Labels = map[string]string{}
+		}
+		report.Environments = append(report.Environments, info)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	out, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(out, '\n'))
+	return err
+}
@@ -0,0 +1,83 @@
+package settings
+
+import (
+	"bytes"
+	"encoding/json"
+	"path"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/matryer/is"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestPrintEnvironments(t *testing.T) {
+	is := is.New(t)
+
+	// Arrange
+
+	tempDir := writeFiles(t, map[string]string{
+		"ltf.yaml": `
+root: true
+environment:
+  owner: platform
+  labels: {team: platform}
+`,
+		"live/ltf.yaml": `
+environment:
+  tier: live
+  protected: true
+`,
+		"live/blue/ltf.yaml": `
+environment:
+  name: live-blue
+  labels: {colour: blue}
+`,
+		"live/blue/modules/ltf.yaml": "hooks: {}\n",
+		"dev/ltf.hcl": `
+environment {
+  name = "dev-${var.region}"
+  tier = "dev"
+}
+`,
+		"dev/.terraform/ltf.yaml": "environment: {name: ignored}\n",
+	})
+	contexts := []string{}
+	context := func(dir string) (*hcl.EvalContext, error) {
+		contexts = append(contexts, dir)
+		return &hcl.EvalContext{Variables: map[string]cty.Value{
+			"var": cty.ObjectVal(map[string]cty.Value{"region": cty.StringVal("eu")}),
+		}}, nil
+	}
+
+	// Act
+
+	var buf bytes.Buffer
+	err := PrintEnvironments(&buf, tempDir, context)
+
+	// Assert
+
+	is.NoErr(err)
+	report := environmentsReport{}
+	is.NoErr(json.Unmarshal(buf.Bytes(), &report))
+	is.Equal(report.Environments, []environmentInfo{
+		{
+			Dir:    "dev",
+			Source: path.Join(tempDir, "dev/ltf.hcl"),
+			Name:   "dev-eu",
+			Tier:   "dev",
+			Owner:  "platform",
+			Labels: map[string]string{"team": "platform"},
+		},
+		{
+			Dir:       "live/blue",
+			Source:    path.Join(tempDir, "live/blue/ltf.yaml"),
+			Name:      "live-blue",
+			Tier:      "live",
+			Owner:     "platform",
+			Labels:    map[string]string{"colour": "blue", "team": "platform"},
+			Protected: true,
+		},
+	}) // only named environments are listed, with inherited metadata
+	is.Equal(len(contexts), 5) // every directory with a settings file is loaded, apart from hidden directories
+}
@@ -0,0 +1,197 @@
+package settings
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"gopkg.in/yaml.v3"
+)
+
+// parseHCLFile parses an ltf.hcl file and evaluates its expressions,
+// returning a YAML node so that it can be checked and decoded in the
+// same way as an ltf.yaml file.
+//
+// Attributes become fields, unlabelled blocks such as `retry {}` become
+// fields named after the block type, and labelled blocks such as
+// `hook "name" {}` are added to a field named after the block type
+// with an "s" added, such as `hooks`.
+func parseHCLFile(file string, ctx *hcl.EvalContext) (*yaml.Node, error) {
+	p := hclparse.NewParser()
+	f, diags := p.ParseHCLFile(file)
+	if diags.HasErrors() {
+		return nil, hclDiagnostics(file, diags)
+	}
+	body, ok := f.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported file format", file)
+	}
+	node, diags := bodyNode(body, ctx)
+	if diags.HasErrors() {
+		return nil, hclDiagnostics(file, diags)
+	}
+	return node, nil
+}
+
+// bodyNode evaluates the attributes and blocks of an HCL body
+// and returns them as a YAML mapping node.
+func bodyNode(body *hclsyntax.Body, ctx *hcl.EvalContext) (*yaml.Node, hcl.Diagnostics) {
+	diags := hcl.Diagnostics{}
+	node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: body.SrcRange.Start.Line, Column: body.SrcRange.Start.Column}
+
+	// Attributes are stored in a map, so sort them into the order of the file.
+	attrs := []*hclsyntax.Attribute{}
+	for _, attr := range body.Attributes {
+		attrs = append(attrs, attr)
+	}
+	sort.Slice(attrs, func(i, j int) bool {
+		return attrs[i].NameRange.Start.Byte < attrs[j].NameRange.Start.Byte
+	})
+
+	for _, attr := range attrs {
+		val, valDiags := attr.Expr.Value(ctx)
+		diags = append(diags, valDiags...)
+		if valDiags.HasErrors() {
+			continue
+		}
+		value, err := valueNode(val, attr.Expr.Range())
+		if err != nil {
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  fmt.Sprintf("Invalid value for %s", attr.Name),
+				Detail:   err.Error(),
+				Subject:  attr.Expr.Range().Ptr(),
+			})
+			continue
+		}
+		node.Content = append(node.Content, keyNode(attr.Name, attr.NameRange), value)
+	}
+
+	labelled := map[string]*yaml.Node{}
+	for _, block := range body.Blocks {
+		value, blockDiags := bodyNode(block.Body, ctx)
+		diags = append(diags, blockDiags...)
+		value.Line = block.TypeRange.Start.Line
+		value.Column = block.TypeRange.Start.Column
+
+		switch len(block.Labels) {
+		case 0:
+			node.Content = append(node.Content, keyNode(block.Type, block.TypeRange), value)
+		case 1:
+			name := block.Type + "s"
+			if _, found := labelled[name]; !found {
+				labelled[name] = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: value.Line, Column: value.Column}
+				node.Content = append(node.Content, keyNode(name, block.TypeRange), labelled[name])
+			}
+			mapping := labelled[name]
+			for i := 0; i < len(mapping.Content); i += 2 {
+				if mapping.Content[i].Value == block.Labels[0] {
+					diags = append(diags, &hcl.Diagnostic{
+						Severity: hcl.DiagError,
+						Summary:  fmt.Sprintf("Duplicate %s block", block.Type),
+						Detail:   fmt.Sprintf("There is already a %s block named %q.", block.Type, block.Labels[0]),
+						Subject:  block.LabelRanges[0].Ptr(),
+					})
+				}
+			}
+			mapping.Content = append(mapping.Content, keyNode(block.Labels[0], block.LabelRanges[0]), value)
+		default:
+			diags = append(diags, &hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  fmt.Sprintf("Too many labels for %s block", block.Type),
+				Detail:   "Blocks can have at most one label, which is used as the name.",
+				Subject:  block.LabelRanges[1].Ptr(),
+			})
+		}
+	}
+
+	return node, diags
+}
+
+// keyNode returns a YAML node for the key of a field.
+func keyNode(name string, rng hcl.Range) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name, Line: rng.Start.Line, Column: rng.Start.Column}
+}
+
+// valueNode converts an evaluated HCL value into a YAML node,
+// using the position of the expression that produced it.
+func valueNode(val cty.Value, rng hcl.Range) (*yaml.Node, error) {
+	node := &yaml.Node{Kind: yaml.ScalarNode, Line: rng.Start.Line, Column: rng.Start.Column}
+
+	if val.IsNull() {
+		node.Tag = "!!null"
+		node.Value = "null"
+		return node, nil
+	}
+	if !val.IsWhollyKnown() {
+		return nil, fmt.Errorf("the value is not known")
+	}
+
+	t := val.Type()
+	switch {
+	case t == cty.String:
+		node.Tag = "!!str"
+		node.Value = val.AsString()
+	case t == cty.Number:
+		f := val.AsBigFloat()
+		node.Value = f.Text('f', -1)
+		if f.IsInt() {
+			node.Tag = "!!int"
+		} else {
+			node.Tag = "!!float"
+		}
+	case t == cty.Bool:
+		node.Tag = "!!bool"
+		node.Value = fmt.Sprint(val.True())
+	case t.IsListType() || t.IsTupleType() || t.IsSetType():
+		node.Kind = yaml.SequenceNode
+		node.Tag = "!!seq"
+		for it := val.ElementIterator(); it.Next(); {
+			_, v := it.Element()
+			item, err := valueNode(v, rng)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, item)
+		}
+	case t.IsMapType() || t.IsObjectType():
+		node.Kind = yaml.MappingNode
+		node.Tag = "!!map"
+		for it := val.ElementIterator(); it.Next(); {
+			k, v := it.Element()
+			item, err := valueNode(v, rng)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, keyNode(k.AsString(), rng), item)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported type %s", t.FriendlyName())
+	}
+
+	return node, nil
+}
+
+// hclDiagnostics converts HCL diagnostics into Diagnostics,
+// so that errors are shown in the same format for both file types.
+func hclDiagnostics(file string, diags hcl.Diagnostics) Diagnostics {
+	result := Diagnostics{}
+	for _, diag := range diags {
+		if diag.Severity != hcl.DiagError {
+			continue
+		}
+		d := Diagnostic{File: file, Message: diag.Summary}
+		if diag.Detail != "" {
+			d.Message += "; " + diag.Detail
+		}
+		if diag.Subject != nil {
+			d.Line = diag.Subject.Start.Line
+			d.Column = diag.Subject.Start.Column
+		}
+		result = append(result, d)
+	}
+	return result
+}
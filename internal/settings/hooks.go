@@ -0,0 +1,149 @@
+package settings
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/raymondbutcher/ltf"
+	"github.com/raymondbutcher/ltf/internal/arguments"
+	"github.com/raymondbutcher/ltf/internal/hook"
+)
+
+// hookInfo describes a hook for the output of "ltf hooks".
+type hookInfo struct {
+	Name      string              `json:"name"`
+	Source    string              `json:"source"`
+	Events    map[string][]string `json:"events"`
+	Group     string              `json:"group,omitempty"`
+	DependsOn []string            `json:"depends_on,omitempty"`
+	If        string              `json:"if,omitempty"`
+}
+
+// hooksReport is the output of "ltf hooks".
+type hooksReport struct {
+	// Hooks holds all hooks in the order that they run.
+	Hooks []hookInfo `json:"hooks"`
+
+	// Command is the simulated command line, if one was given.
+	Command string `json:"command,omitempty"`
+
+	// Matches holds the names of the hooks that match the simulated
+	// command for each event, in the order that they run.
+	Matches map[string][]string `json:"matches,omitempty"`
+}
+
+// PrintHooks writes the hooks in the order that they run, along with the
+// settings file that each hook came from. If command is not empty, it is
+// treated as a simulated command line, such as "terraform plan", and the
+// hooks that match it are shown for each event. Hook conditions are shown
+// but not evaluated, because they depend on the full environment.
+func (s *settings) PrintHooks(w io.Writer, command []string, env ltf.Environ, asJSON bool) error {
+	sorted, err := s.Hooks.Sorted()
+	if err != nil {
+		return err
+	}
+
+	report := hooksReport{Hooks: []hookInfo{}}
+	for _, h := range sorted {
+		info := hookInfo{
+			Name:      h.Name,
+			Source:    s.Sources["hooks."+h.Name],
+			Events:    map[string][]string{},
+			Group:     h.Group,
+			DependsOn: h.DependsOn,
+			If:        h.If,
+		}
+		for _, when := range hook.Events {
+			if patterns := h.Patterns(when); len(patterns) > 0 {
+				info.Events[when] = patterns
+			}
+		}
+		report.Hooks = append(report.Hooks, info)
+	}
+
+	if len(command) > 0 {
+		if command[0] != "terraform" && command[0] != "ltf" {
+			command = append([]string{"terraform"}, command...)
+		}
+		args, err := arguments.New(command, env)
+		if err != nil {
+			return fmt.Errorf("parsing command: %w", err)
+		}
+		report.Command = strings.Join(command, " ")
+		report.Matches = map[string][]string{}
+		for _, when := range hook.Events {
+			names := []string{}
+			for _, h := range sorted {
+				if h.Match(when, args) {
+					names = append(names, h.Name)
+				}
+			}
+			report.Matches[when] = names
+		}
+	}
+
+	if asJSON {
+		out, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, string(out))
+		return nil
+	}
+
+	if report.Matches == nil {
+		if len(report.Hooks) == 0 {
+			fmt.Fprintln(w, "# No hooks found")
+		}
+		for i, info := range report.Hooks {
+			if i > 0 {
+				fmt.Fprintln(w, "")
+			}
+			fmt.Fprintf(w, "%d. %s\n", i+1, info.Name)
+			fmt.Fprintf(w, "   from: %s\n", info.Source)
+			for _, when := range hook.Events {
+				if patterns, found := info.Events[when]; found {
+					fmt.Fprintf(w, "   %s: %s\n", when, strings.Join(patterns, ", "))
+				}
+			}
+			if info.Group != "" {
+				fmt.Fprintf(w, "   group: %s\n", info.Group)
+			}
+			if len(info.DependsOn) > 0 {
+				fmt.Fprintf(w, "   depends_on: %s\n", strings.Join(info.DependsOn, ", "))
+			}
+			if info.If != "" {
+				fmt.Fprintf(w, "   if: %s\n", info.If)
+			}
+		}
+		return nil
+	}
+
+	sources := map[string]hookInfo{}
+	for _, info := range report.Hooks {
+		sources[info.Name] = info
+	}
+	fmt.Fprintf(w, "# Hooks matching: %s\n", report.Command)
+	found := false
+	for _, when := range hook.Events {
+		names := report.Matches[when]
+		if len(names) == 0 {
+			continue
+		}
+		found = true
+		fmt.Fprintf(w, "%s:\n", when)
+		for i, name := range names {
+			info := sources[name]
+			fmt.Fprintf(w, "  %d. %s (from %s)\n", i+1, name, info.Source)
+			if info.If != "" {
+				fmt.Fprintf(w, "     if: %s\n", info.If)
+			}
+		}
+	}
+	if !found {
+		fmt.Fprintln(w, "# No hooks match")
+	}
+	return nil
+}
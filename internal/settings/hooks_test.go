@@ -0,0 +1,108 @@
+package settings
+
+import (
+	"bytes"
+	"encoding/json"
+	"path"
+	"testing"
+
+	"github.com/matryer/is"
+	"github.com/raymondbutcher/ltf"
+)
+
+func TestPrintHooks(t *testing.T) {
+	tempDir := writeFiles(t, map[string]string{
+		"ltf.yaml": `
+root: true
+hooks:
+  login:
+    before: [terraform]
+    finally: [terraform]
+    script: echo login
+`,
+		"live/ltf.yaml": `
+hooks:
+  check:
+    before: [terraform plan]
+    depends_on: [login]
+    if: var.env == "live"
+    script: echo check
+  notify:
+    failed: [terraform apply]
+    script: echo failed
+`,
+	})
+	s, err := Load(path.Join(tempDir, "live"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("all hooks", func(t *testing.T) {
+		is := is.New(t)
+
+		// Act
+
+		var buf bytes.Buffer
+		err := s.PrintHooks(&buf, nil, ltf.NewEnviron(), false)
+
+		// Assert
+
+		is.NoErr(err)
+		is.Equal(buf.String(), "1. login\n"+
+			"   from: "+path.Join(tempDir, "ltf.yaml")+"\n"+
+			"   before: terraform\n"+
+			"   finally: terraform\n"+
+			"\n"+
+			"2. check\n"+
+			"   from: "+path.Join(tempDir, "live/ltf.yaml")+"\n"+
+			"   before: terraform plan\n"+
+			"   depends_on: login\n"+
+			"   if: var.env == \"live\"\n"+
+			"\n"+
+			"3. notify\n"+
+			"   from: "+path.Join(tempDir, "live/ltf.yaml")+"\n"+
+			"   failed: terraform apply\n")
+	})
+
+	t.Run("matching a command", func(t *testing.T) {
+		is := is.New(t)
+
+		// Act
+
+		var buf bytes.Buffer
+		err := s.PrintHooks(&buf, []string{"plan", "-var=env=live"}, ltf.NewEnviron(), false)
+
+		// Assert
+
+		is.NoErr(err)
+		is.Equal(buf.String(), "# Hooks matching: terraform plan -var=env=live\n"+
+			"before:\n"+
+			"  1. login (from "+path.Join(tempDir, "ltf.yaml")+")\n"+
+			"  2. check (from "+path.Join(tempDir, "live/ltf.yaml")+")\n"+
+			"     if: var.env == \"live\"\n"+
+			"finally:\n"+
+			"  1. login (from "+path.Join(tempDir, "ltf.yaml")+")\n")
+	})
+
+	t.Run("json", func(t *testing.T) {
+		is := is.New(t)
+
+		// Act
+
+		var buf bytes.Buffer
+		err := s.PrintHooks(&buf, []string{"terraform", "apply"}, ltf.NewEnviron(), true)
+
+		// Assert
+
+		is.NoErr(err)
+		report := hooksReport{}
+		is.NoErr(json.Unmarshal(buf.Bytes(), &report))
+		is.Equal(len(report.Hooks), 3)
+		is.Equal(report.Hooks[2].Source, path.Join(tempDir, "live/ltf.yaml"))
+		is.Equal(report.Command, "terraform apply")
+		is.Equal(report.Matches["before"], []string{"login"})
+		is.Equal(report.Matches["after"], []string{})
+		is.Equal(report.Matches["failed"], []string{"notify"})
+		is.Equal(report.Matches["finally"], []string{"login"})
+	})
+}
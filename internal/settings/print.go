@@ -0,0 +1,100 @@
+package settings
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Print writes the merged settings in YAML format,
+// with comments showing the file that each setting came from.
+func (s *settings) Print(w io.Writer) error {
+	fmt.Fprintln(w, "# Settings files:")
+	if len(s.Files) == 0 {
+		fmt.Fprintln(w, "#   (none)")
+	}
+	for _, file := range s.Files {
+		fmt.Fprintf(w, "#   %s\n", file)
+	}
+
+	if s.Binary != "" {
+		fmt.Fprintf(w, "# from %s\n", s.Sources["binary"])
+		fmt.Fprintf(w, "binary: %s\n", s.Binary)
+	}
+
+	if s.Environment != nil {
+		fmt.Fprintf(w, "# from %s\n", s.Sources["environment"])
+		if err := printYAML(w, map[string]interface{}{"environment": s.Environment}, ""); err != nil {
+			return err
+		}
+	}
+
+	if s.Versions != nil {
+		fmt.Fprintf(w, "# from %s\n", s.Sources["versions"])
+		if err := printYAML(w, map[string]interface{}{"versions": s.Versions}, ""); err != nil {
+			return err
+		}
+	}
+
+	sections := []struct {
+		name  string
+		items map[string]interface{}
+	}{
+		{"commands", map[string]interface{}{}},
+		{"hooks", map[string]interface{}{}},
+		{"timeouts", map[string]interface{}{}},
+		{"args", map[string]interface{}{}},
+	}
+	for name, c := range s.Commands {
+		sections[0].items[name] = c
+	}
+	for name, h := range s.Hooks {
+		sections[1].items[name] = h
+	}
+	for name, t := range s.Timeouts {
+		sections[2].items[name] = t.String()
+	}
+	for name, a := range s.Args {
+		sections[3].items[name] = a
+	}
+
+	for _, section := range sections {
+		if len(section.items) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%s:\n", section.name)
+
+		names := []string{}
+		for name := range section.items {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			fmt.Fprintf(w, "  # from %s\n", s.Sources[section.name+"."+name])
+			if err := printYAML(w, map[string]interface{}{name: section.items[name]}, "  "); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// printYAML writes a value in YAML format, with each line indented.
+func printYAML(w io.Writer, value interface{}, indent string) error {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(value); err != nil {
+		return err
+	}
+	for _, line := range strings.Split(strings.TrimRight(buf.String(), "\n"), "\n") {
+		fmt.Fprintf(w, "%s%s\n", indent, line)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package settings
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// durationPattern matches the durations accepted by time.ParseDuration.
+const durationPattern = `^-?([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Schema returns a JSON Schema for ltf.yaml files,
+// generated from the Go types used to load them.
+func Schema() ([]byte, error) {
+	schema := typeSchema(reflect.TypeOf(settings{}))
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["title"] = "LTF settings file (ltf.yaml)"
+	out, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// typeSchema returns the JSON Schema for a Go type.
+func typeSchema(t reflect.Type) map[string]interface{} {
+	if t == durationType {
+		return map[string]interface{}{"type": "string", "pattern": durationPattern}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem())
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		for _, field := range yamlFields(t) {
+			properties[field.name] = typeSchema(field.typ)
+		}
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": typeSchema(t.Elem()),
+		}
+	case reflect.Slice:
+		return map[string]interface{}{
+			"type":  "array",
+			"items": typeSchema(t.Elem()),
+		}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	default:
+		return map[string]interface{}{"type": "string"}
+	}
+}
+
+// yamlField is a struct field that can be set in a settings file.
+type yamlField struct {
+	name  string
+	index int
+	typ   reflect.Type
+}
+
+// yamlFields returns the fields of a struct type that have YAML names.
+func yamlFields(t reflect.Type) []yamlField {
+	fields := []yamlField{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		fields = append(fields, yamlField{name: name, index: i, typ: field.Type})
+	}
+	return fields
+}
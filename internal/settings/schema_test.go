@@ -0,0 +1,26 @@
+package settings
+
+import (
+	"os"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestSchema(t *testing.T) {
+	is := is.New(t)
+
+	// Arrange
+
+	published, err := os.ReadFile("../../ltf.schema.json")
+	is.NoErr(err)
+
+	// Act
+
+	schema, err := Schema()
+
+	// Assert
+
+	is.NoErr(err)
+	is.Equal(string(schema), string(published)) // run "ltf schema > ltf.schema.json" to update it
+}
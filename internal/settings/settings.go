@@ -1,65 +1,249 @@
 package settings
 
 import (
+	"fmt"
 	"io/ioutil"
 	"path"
-	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
 
+	"github.com/hashicorp/hcl/v2"
+	"github.com/raymondbutcher/ltf/internal/command"
+	"github.com/raymondbutcher/ltf/internal/environment"
 	"github.com/raymondbutcher/ltf/internal/filesystem"
 	"github.com/raymondbutcher/ltf/internal/hook"
-	"gopkg.in/yaml.v2"
+	"github.com/raymondbutcher/ltf/internal/versions"
+	"gopkg.in/yaml.v3"
 )
 
 type settings struct {
-	Hooks hook.Hooks `yaml:"hooks"`
+	// Root stops LTF from looking for settings files in parent directories.
+	Root bool `yaml:"root"`
+
+	// Binary is the Terraform or OpenTofu command to run, such as "tofu"
+	// or the path to a pinned version. Relative paths are relative to the
+	// directory of the settings file.
+	Binary string `yaml:"binary"`
+
+	// Versions configures finding a Terraform binary that matches the
+	// required_version constraints of the configuration. It is not used
+	// if Binary or LTF_TERRAFORM_BINARY is set.
+	Versions *versions.Versions `yaml:"versions"`
+
+	// Environment holds metadata about the environment directory. Fields from
+	// deeper directories override fields from parent directories.
+	Environment *environment.Environment `yaml:"environment"`
+
+	Commands command.Commands         `yaml:"commands"`
+	Hooks    hook.Hooks               `yaml:"hooks"`
+	Timeouts map[string]time.Duration `yaml:"timeouts"`
+
+	// Args holds default command line arguments for each subcommand, which are
+	// added to the TF_CLI_ARGS_$subcommand environment variable. Arguments from
+	// deeper directories are added after arguments from parent directories.
+	Args map[string][]string `yaml:"args"`
+
+	// Files holds the paths of the settings files that were loaded,
+	// starting with the highest directory.
+	Files []string `yaml:"-"`
+
+	// Sources holds the path of the settings file that each setting came from,
+	// using keys such as "binary", "versions", "hooks.$name", "commands.$name",
+	// "timeouts.$subcommand", "args.$subcommand" and "environment". Args and
+	// environment can come from multiple files, which are separated by commas.
+	Sources map[string]string `yaml:"-"`
 }
 
-func Load(cwd string) (*settings, error) {
-	file, err := findFile(cwd)
+// Load finds and loads ltf.yaml and ltf.hcl files in the current and parent
+// directories, and merges them together. Files in deeper directories take
+// precedence over files in parent directories. It stops looking in parent
+// directories after finding a file with `root: true`. Expressions in ltf.hcl
+// files are evaluated with ctx, which may be nil.
+func Load(cwd string, ctx *hcl.EvalContext) (*settings, error) {
+	files, parsed, err := findFiles(cwd, ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	settings := settings{}
+	merged := settings{
+		Commands: command.Commands{},
+		Hooks:    hook.Hooks{},
+		Timeouts: map[string]time.Duration{},
+		Args:     map[string][]string{},
+		Files:    []string{},
+		Sources:  map[string]string{},
+	}
 
-	if file == "" {
-		return &settings, nil
+	// Start at the highest directory and go deeper towards the current directory.
+	for i := len(files) - 1; i >= 0; i-- {
+		file := files[i]
+		if err := merged.merge(parsed[i], file); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
 	}
 
-	rel, err := filepath.Rel(cwd, file)
-	if err != nil {
+	if err := merged.Commands.Validate(); err != nil {
 		return nil, err
 	}
 
-	content, err := ioutil.ReadFile(rel)
-	if err != nil {
+	if _, err := merged.Hooks.Sorted(); err != nil {
 		return nil, err
 	}
 
-	if err := yaml.UnmarshalStrict([]byte(content), &settings); err != nil {
-		return nil, err
+	return &merged, nil
+}
+
+// merge adds the settings from a file, replacing any existing settings
+// with the same names. Hooks and commands with `disabled: true` remove
+// existing hooks and commands with the same names.
+func (s *settings) merge(other *settings, file string) error {
+	s.Files = append(s.Files, file)
+
+	if other.Binary != "" {
+		s.Binary = other.Binary
+		if strings.Contains(s.Binary, "/") && !path.IsAbs(s.Binary) {
+			s.Binary = path.Join(path.Dir(file), s.Binary)
+		}
+		s.Sources["binary"] = file
+	}
+
+	if other.Versions != nil {
+		if err := other.Versions.Validate(); err != nil {
+			return err
+		}
+		s.Versions = other.Versions
+		if dir := s.Versions.Dir; dir != "" && dir[0] != '~' && !path.IsAbs(dir) {
+			s.Versions.Dir = path.Join(path.Dir(file), dir)
+		}
+		s.Sources["versions"] = file
+	}
+
+	for name, c := range other.Commands {
+		if c == nil {
+			return fmt.Errorf("command %s is empty", name)
+		}
+		c.Name = name
+		if c.Disabled {
+			delete(s.Commands, name)
+			delete(s.Sources, "commands."+name)
+			continue
+		}
+		s.Commands[name] = c
+		s.Sources["commands."+name] = file
+	}
+
+	for name, h := range other.Hooks {
+		if h == nil {
+			return fmt.Errorf("hook %s is empty", name)
+		}
+		h.Name = name
+		if h.Disabled {
+			delete(s.Hooks, name)
+			delete(s.Sources, "hooks."+name)
+			continue
+		}
+		if err := h.Validate(); err != nil {
+			return err
+		}
+		s.Hooks[name] = h
+		s.Sources["hooks."+name] = file
+	}
+
+	for subcommand, timeout := range other.Timeouts {
+		s.Timeouts[subcommand] = timeout
+		s.Sources["timeouts."+subcommand] = file
+	}
+
+	for subcommand, args := range other.Args {
+		if len(args) == 0 {
+			continue
+		}
+		s.Args[subcommand] = append(s.Args[subcommand], args...)
+		s.addSource("args."+subcommand, file)
+	}
+
+	if other.Environment != nil {
+		if s.Environment == nil {
+			s.Environment = &environment.Environment{}
+		}
+		s.Environment.Merge(other.Environment)
+		s.addSource("environment", file)
+	}
+
+	return nil
+}
+
+// addSource adds a file to the sources of a setting
+// that can come from multiple files.
+func (s *settings) addSource(key string, file string) {
+	if source := s.Sources[key]; source != "" {
+		s.Sources[key] = source + ", " + file
+	} else {
+		s.Sources[key] = file
 	}
+}
 
-	for name, hook := range settings.Hooks {
-		hook.Name = name
+// readFile reads a single settings file. It returns Diagnostics with
+// the line and column of any unknown fields or values of the wrong type.
+func readFile(file string, ctx *hcl.EvalContext) (*settings, error) {
+	s := settings{}
+	node := &yaml.Node{}
+	if path.Ext(file) == ".hcl" {
+		var err error
+		node, err = parseHCLFile(file, ctx)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(content, node); err != nil {
+			return nil, syntaxError(file, err)
+		}
+		if node.Kind == 0 {
+			// The file is empty.
+			return &s, nil
+		}
+	}
+	if diags := checkNode(file, node, reflect.TypeOf(s), ""); len(diags) > 0 {
+		return nil, diags
+	}
+	if err := node.Decode(&s); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
 	}
 
-	return &settings, nil
+	return &s, nil
 }
 
-// findFile returns the path to ltf.yaml in the current or parent directories,
-// or an empty string if not found.
-func findFile(dir string) (string, error) {
+// findFiles returns the paths to ltf.yaml and ltf.hcl files in the current and
+// parent directories, starting with the current directory, along with their
+// parsed contents. It stops after finding a file with `root: true`.
+func findFiles(dir string, ctx *hcl.EvalContext) (files []string, parsed []*settings, err error) {
 	lastDir := ""
 	for {
 		// Check this directory.
 		names, err := filesystem.ReadNames(dir)
 		if err != nil {
-			return "", err
+			return nil, nil, err
+		}
+		matches := filesystem.MatchNames(names, "ltf.yaml")
+		matches = append(matches, filesystem.MatchNames(names, "ltf.hcl")...)
+		if len(matches) > 1 {
+			return nil, nil, fmt.Errorf("%s: cannot use both ltf.yaml and ltf.hcl in the same directory", dir)
 		}
-		for _, name := range names {
-			if name == "ltf.yaml" {
-				return path.Join(dir, name), nil
+		for _, name := range matches {
+			file := path.Join(dir, name)
+			s, err := readFile(file, ctx)
+			if err != nil {
+				return nil, nil, err
+			}
+			files = append(files, file)
+			parsed = append(parsed, s)
+			if s.Root {
+				return files, parsed, nil
 			}
 		}
 
@@ -74,6 +258,5 @@ func findFile(dir string) (string, error) {
 		lastDir = dir
 	}
 
-	// Not found.
-	return "", nil
+	return files, parsed, nil
 }
@@ -0,0 +1,309 @@
+package settings
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/matryer/is"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	tempDir, err := os.MkdirTemp("", "ltf-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(tempDir) })
+	for name, contents := range files {
+		filePath := path.Join(tempDir, name)
+		if err := os.MkdirAll(path.Dir(filePath), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filePath, []byte(contents), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return tempDir
+}
+
+func TestLoad(t *testing.T) {
+	is := is.New(t)
+
+	// Arrange
+
+	tempDir := writeFiles(t, map[string]string{
+		"ltf.yaml": `
+hooks:
+  ignored:
+    before: [terraform]
+    script: echo ignored because of root below
+`,
+		"project/ltf.yaml": `
+root: true
+hooks:
+  greeting:
+    before: [terraform]
+    script: echo hello
+  overridden:
+    before: [terraform]
+    script: echo root
+  removed:
+    before: [terraform]
+    script: echo removed
+timeouts:
+  plan: 10m
+  apply: 1h
+args:
+  plan: [-lock-timeout=5m]
+`,
+		"project/live/ltf.yaml": `
+hooks:
+  overridden:
+    after: [terraform apply]
+    script: echo live
+  removed:
+    disabled: true
+  extra:
+    before: [terraform plan]
+    script: echo extra
+timeouts:
+  apply: 2h
+args:
+  plan: [-parallelism=5]
+`,
+	})
+	cwd := path.Join(tempDir, "project", "live")
+
+	// Act
+
+	s, err := Load(cwd, nil)
+
+	// Assert
+
+	is.NoErr(err)
+	is.Equal(s.Files, []string{path.Join(tempDir, "project/ltf.yaml"), path.Join(tempDir, "project/live/ltf.yaml")})
+
+	is.Equal(len(s.Hooks), 3)
+	is.Equal(s.Hooks["greeting"].Script, "echo hello")
+	is.Equal(s.Hooks["overridden"].Script, "echo live")
+	is.Equal(s.Hooks["overridden"].Before, nil) // hooks are replaced, not merged
+	is.Equal(s.Hooks["extra"].Name, "extra")
+	is.True(s.Hooks["removed"] == nil)
+	is.True(s.Hooks["ignored"] == nil)
+
+	is.Equal(s.Timeouts["plan"], 10*time.Minute)
+	is.Equal(s.Timeouts["apply"], 2*time.Hour)
+
+	is.Equal(s.Sources["hooks.greeting"], path.Join(tempDir, "project/ltf.yaml"))
+	is.Equal(s.Sources["hooks.overridden"], path.Join(tempDir, "project/live/ltf.yaml"))
+	is.Equal(s.Sources["timeouts.apply"], path.Join(tempDir, "project/live/ltf.yaml"))
+
+	is.Equal(s.Args["plan"], []string{"-lock-timeout=5m", "-parallelism=5"}) // args are added to
+	is.Equal(s.Sources["args.plan"], path.Join(tempDir, "project/ltf.yaml")+", "+path.Join(tempDir, "project/live/ltf.yaml"))
+
+	t.Run("print", func(t *testing.T) {
+		is := is.New(t)
+
+		var buf bytes.Buffer
+		err := s.Print(&buf)
+
+		is.NoErr(err)
+		out := buf.String()
+		is.True(strings.Contains(out, "  # from "+path.Join(tempDir, "project/live/ltf.yaml")+"\n  overridden:\n"))
+		is.True(strings.Contains(out, "timeouts:\n"))
+	})
+}
+
+func TestLoadHCL(t *testing.T) {
+	is := is.New(t)
+
+	// Arrange
+
+	tempDir := writeFiles(t, map[string]string{
+		"ltf.hcl": `
+root = true
+
+hook "notify" {
+  after  = var.env == "live" ? ["terraform apply"] : []
+  script = "echo ${env.GREETING} from ${var.env}"
+  retry {
+    attempts = 2
+    delay    = "1s"
+  }
+}
+
+command "bootstrap" {
+  script = "echo bootstrap"
+}
+
+timeouts = {
+  apply = var.env == "live" ? "2h" : "30m"
+}
+`,
+		"live/ltf.yaml": `
+hooks:
+  extra:
+    before: [terraform plan]
+    script: echo extra
+`,
+	})
+	ctx := &hcl.EvalContext{Variables: map[string]cty.Value{
+		"var": cty.ObjectVal(map[string]cty.Value{"env": cty.StringVal("live")}),
+		"env": cty.ObjectVal(map[string]cty.Value{"GREETING": cty.StringVal("hello")}),
+	}}
+
+	// Act
+
+	s, err := Load(path.Join(tempDir, "live"), ctx)
+
+	// Assert
+
+	is.NoErr(err)
+	is.Equal(s.Files, []string{path.Join(tempDir, "ltf.hcl"), path.Join(tempDir, "live/ltf.yaml")})
+	is.Equal(s.Hooks["notify"].After, []string{"terraform apply"})
+	is.Equal(s.Hooks["notify"].Script, "echo hello from live")
+	is.Equal(s.Hooks["notify"].Retry.Attempts, 2)
+	is.Equal(s.Hooks["notify"].Retry.Delay, time.Second)
+	is.Equal(s.Hooks["extra"].Script, "echo extra")
+	is.Equal(s.Commands["bootstrap"].Script, "echo bootstrap")
+	is.Equal(s.Timeouts["apply"], 2*time.Hour)
+}
+
+func TestLoadBinary(t *testing.T) {
+	is := is.New(t)
+
+	// Arrange
+
+	tempDir := writeFiles(t, map[string]string{
+		"ltf.yaml":        "binary: tofu\n",
+		"dev/ltf.yaml":    "hooks: {}\n",
+		"pinned/ltf.yaml": "binary: ../bin/terraform\n",
+	})
+
+	// Act
+
+	dev, devErr := Load(path.Join(tempDir, "dev"), nil)
+	pinned, pinnedErr := Load(path.Join(tempDir, "pinned"), nil)
+
+	// Assert
+
+	is.NoErr(devErr)
+	is.Equal(dev.Binary, "tofu")
+	is.Equal(dev.Sources["binary"], path.Join(tempDir, "ltf.yaml"))
+
+	is.NoErr(pinnedErr)
+	is.Equal(pinned.Binary, path.Join(tempDir, "bin/terraform")) // relative to the settings file
+}
+
+func TestLoadWithoutFiles(t *testing.T) {
+	is := is.New(t)
+
+	tempDir := writeFiles(t, map[string]string{"main.tf": ""})
+
+	s, err := Load(tempDir, nil)
+
+	is.NoErr(err)
+	is.Equal(len(s.Hooks), 0)
+	is.Equal(len(s.Commands), 0)
+}
+
+func TestLoadErrors(t *testing.T) {
+	t.Run("unknown field", func(t *testing.T) {
+		is := is.New(t)
+
+		tempDir := writeFiles(t, map[string]string{"ltf.yaml": "root: true\nhookz: {}\n"})
+
+		_, err := Load(tempDir, nil)
+
+		is.True(err != nil)
+		is.True(strings.Contains(err.Error(), path.Join(tempDir, "ltf.yaml"))) // error should include the file
+	})
+
+	t.Run("typo", func(t *testing.T) {
+		is := is.New(t)
+
+		tempDir := writeFiles(t, map[string]string{"ltf.yaml": "hooks:\n  greeting:\n    befor: [terraform]\n    script: echo hello\n"})
+
+		_, err := Load(tempDir, nil)
+
+		is.True(err != nil)
+		is.Equal(err.Error(), path.Join(tempDir, "ltf.yaml")+`:3:5: unknown field "befor" in hooks.greeting, did you mean "before"?`)
+	})
+
+	t.Run("wrong type", func(t *testing.T) {
+		is := is.New(t)
+
+		tempDir := writeFiles(t, map[string]string{"ltf.yaml": "hooks:\n  greeting:\n    before: terraform\n    script: echo hello\n"})
+
+		_, err := Load(tempDir, nil)
+
+		is.True(err != nil)
+		is.True(strings.HasPrefix(err.Error(), path.Join(tempDir, "ltf.yaml")+":3:13: hooks.greeting.before")) // error should include the line and column
+	})
+
+	t.Run("integer duration", func(t *testing.T) {
+		is := is.New(t)
+
+		tempDir := writeFiles(t, map[string]string{"ltf.yaml": "timeouts:\n  plan: 30\n"})
+
+		_, err := Load(tempDir, nil)
+
+		is.True(err != nil)
+		is.Equal(err.Error(), path.Join(tempDir, "ltf.yaml")+`:2:9: timeouts.plan must be a duration, such as 30s or 10m, not "30"`)
+	})
+
+	t.Run("syntax error", func(t *testing.T) {
+		is := is.New(t)
+
+		tempDir := writeFiles(t, map[string]string{"ltf.yaml": "hooks:\n  greeting: [\n"})
+
+		_, err := Load(tempDir, nil)
+
+		is.True(err != nil)
+		is.True(strings.HasPrefix(err.Error(), path.Join(tempDir, "ltf.yaml")+":2: ")) // error should include the line
+	})
+
+	t.Run("hcl typo", func(t *testing.T) {
+		is := is.New(t)
+
+		tempDir := writeFiles(t, map[string]string{"ltf.hcl": "hook \"greeting\" {\n  befor  = [\"terraform\"]\n  script = \"echo hello\"\n}\n"})
+
+		_, err := Load(tempDir, nil)
+
+		is.True(err != nil)
+		is.Equal(err.Error(), path.Join(tempDir, "ltf.hcl")+`:2:3: unknown field "befor" in hooks.greeting, did you mean "before"?`)
+	})
+
+	t.Run("hcl and yaml", func(t *testing.T) {
+		is := is.New(t)
+
+		tempDir := writeFiles(t, map[string]string{"ltf.hcl": "", "ltf.yaml": ""})
+
+		_, err := Load(tempDir, nil)
+
+		is.True(err != nil)
+		is.True(strings.Contains(err.Error(), "cannot use both ltf.yaml and ltf.hcl"))
+	})
+
+	t.Run("dependency across files", func(t *testing.T) {
+		is := is.New(t)
+
+		tempDir := writeFiles(t, map[string]string{
+			"ltf.yaml":     "root: true\nhooks:\n  login:\n    before: [terraform]\n    script: echo login\n",
+			"dev/ltf.yaml": "hooks:\n  use:\n    before: [terraform]\n    script: echo use\n    depends_on: [login]\n",
+		})
+
+		s, err := Load(path.Join(tempDir, "dev"), nil)
+
+		is.NoErr(err)
+		sorted, err := s.Hooks.Sorted()
+		is.NoErr(err)
+		is.Equal(sorted[0].Name, "login")
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/raymondbutcher/ltf/internal/redact"
 	"github.com/zclconf/go-cty/cty"
 	"github.com/zclconf/go-cty/cty/json"
 )
@@ -29,7 +30,7 @@ func (v *Variable) Print() {
 	if v.Sensitive {
 		fmt.Fprintf(os.Stderr, "+ TF_VAR_%s=%s\n", v.Name, "(sensitive value)")
 	} else {
-		fmt.Fprintf(os.Stderr, "+ TF_VAR_%s=%s\n", v.Name, v.StringValue)
+		redact.Fprintf(os.Stderr, "+ TF_VAR_%s=%s\n", v.Name, v.StringValue)
 	}
 }
 
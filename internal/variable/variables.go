@@ -8,10 +8,12 @@ import (
 	"sort"
 	"strings"
 
-	"github.com/hashicorp/terraform-config-inspect/tfconfig"
+	"github.com/hashicorp/hcl/v2"
 	"github.com/raymondbutcher/ltf/internal/arguments"
 	"github.com/raymondbutcher/ltf/internal/filesystem"
 	"github.com/tmccombs/hcl2json/convert"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/gocty"
 )
 
 type Variables map[string]*Variable
@@ -45,6 +47,27 @@ func (vars Variables) SetValue(name string, value string, freeze bool) (v *Varia
 	return v, nil
 }
 
+// SetAnyValue is like SetValue, but it accepts a decoded JSON value such as
+// those returned by ParseValues. New variables get the "any" type if the value
+// is not a string, so that lists, maps and objects keep their structure instead
+// of being treated as strings. Existing variables keep their type.
+func (vars Variables) SetAnyValue(name string, value interface{}, freeze bool) (*Variable, error) {
+	str, err := marshalValue(value)
+	if err != nil {
+		return nil, err
+	}
+	if _, found := vars[name]; !found {
+		if _, isString := value.(string); !isString {
+			v, err := New(name, "any", str)
+			if err != nil {
+				return nil, err
+			}
+			vars[name] = v
+		}
+	}
+	return vars.SetValue(name, str, freeze)
+}
+
 // SetValues sets multiple variable values. It uses the same freeze logic as SetValue.
 func (vars Variables) SetValues(values map[string]string, freeze bool) error {
 	for name, value := range values {
@@ -55,6 +78,27 @@ func (vars Variables) SetValues(values map[string]string, freeze bool) error {
 	return nil
 }
 
+// EvalContext returns an HCL EvalContext with a `var` object containing the variables.
+func (vars Variables) EvalContext() (*hcl.EvalContext, error) {
+	values := map[string]cty.Value{}
+	for _, v := range vars {
+		ct, err := gocty.ImpliedType(v.AnyValue)
+		if err != nil {
+			return nil, fmt.Errorf("getting cty type for var.%s (%v): %w", v.Name, v.AnyValue, err)
+		}
+		cv, err := gocty.ToCtyValue(v.AnyValue, ct)
+		if err != nil {
+			return nil, fmt.Errorf("converting to cty type: %w", err)
+		}
+		values[v.Name] = cv
+	}
+	ctx := hcl.EvalContext{}
+	ctx.Variables = map[string]cty.Value{
+		"var": cty.ObjectVal(values),
+	}
+	return &ctx, nil
+}
+
 // Load returns variables from CLI arguments, environment variables,
 // the Terraform configuration, and tfvars files.
 //
@@ -69,7 +113,8 @@ func Load(args *arguments.Arguments, dirs []string, chdir string) (vars Variable
 	vars = Variables{}
 
 	// Parse the Terraform config to get variable types and defaults.
-	module, diags := tfconfig.LoadModule(chdir)
+	// This includes OpenTofu *.tofu and *.tofu.json files.
+	module, diags := filesystem.LoadModule(chdir)
 	if err := diags.Err(); err != nil {
 		return nil, err
 	}
@@ -104,17 +149,8 @@ func Load(args *arguments.Arguments, dirs []string, chdir string) (vars Variable
 	}
 
 	// Load variables from CLI arguments.
-	// Terraform will prefer these values over TF_VAR_name so freeze them
-	// so LTF can return an error if something tries to set a different
-	// value using TF_VAR_name.
-	if v, err := readVariablesArgs(args.Virtual); err != nil {
+	if _, err := vars.LoadArgs(args); err != nil {
 		return nil, err
-	} else {
-		for name, value := range v {
-			if _, err := vars.SetValue(name, value, true); err != nil {
-				return nil, err
-			}
-		}
 	}
 
 	// Load variables from *.tfvars and *.tfvars.json files.
@@ -140,6 +176,38 @@ func Load(args *arguments.Arguments, dirs []string, chdir string) (vars Variable
 	return vars, nil
 }
 
+// LoadArgs sets variables from -var and -var-file arguments, and returns
+// the variables that were changed, sorted by name.
+// Terraform will prefer these values over TF_VAR_name so freeze them
+// so LTF can return an error if something tries to set a different
+// value using TF_VAR_name.
+func (vars Variables) LoadArgs(args *arguments.Arguments) ([]*Variable, error) {
+	values, err := readVariablesArgs(args.Virtual)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	changed := []*Variable{}
+	for _, name := range names {
+		if existing, found := vars[name]; found && existing.Frozen && existing.StringValue == values[name] {
+			continue
+		}
+		v, err := vars.SetValue(name, values[name], true)
+		if err != nil {
+			return nil, err
+		}
+		changed = append(changed, v)
+	}
+
+	return changed, nil
+}
+
 func filterVariableFiles(files []string) (matches []string) {
 	// Returns variables files in the correct order of precedence.
 	// https://www.terraform.io/language/values/variables#variable-definition-precedence
@@ -239,20 +307,9 @@ func readVariablesFile(filename string) (map[string]string, error) {
 		return nil, err
 	}
 
-	var jsonBytes []byte
-
-	if strings.HasSuffix(filename, ".json") {
-		jsonBytes = bytes
-	} else {
-		jsonBytes, err = convert.Bytes(bytes, filename, convert.Options{})
-		if err != nil {
-			return nil, fmt.Errorf("readVariablesFile converting hcl to json: %w", err)
-		}
-	}
-
-	vars := map[string]interface{}{}
-	if err := json.Unmarshal(jsonBytes, &vars); err != nil {
-		return nil, fmt.Errorf("readVariablesFile writing json: %w", err)
+	vars, err := ParseValues(bytes, filename)
+	if err != nil {
+		return nil, fmt.Errorf("readVariablesFile %w", err)
 	}
 
 	for name, val := range vars {
@@ -265,3 +322,26 @@ func readVariablesFile(filename string) (map[string]string, error) {
 
 	return result, nil
 }
+
+// ParseValues parses variable values from the contents of a tfvars file,
+// or a tfvars.json file if the filename ends with ".json" or the content
+// is a JSON object. The values are returned as decoded JSON values.
+func ParseValues(content []byte, filename string) (map[string]interface{}, error) {
+	var jsonBytes []byte
+	if strings.HasSuffix(filename, ".json") || strings.HasPrefix(strings.TrimSpace(string(content)), "{") {
+		jsonBytes = content
+	} else {
+		var err error
+		jsonBytes, err = convert.Bytes(content, filename, convert.Options{})
+		if err != nil {
+			return nil, fmt.Errorf("converting hcl to json: %w", err)
+		}
+	}
+
+	vars := map[string]interface{}{}
+	if err := json.Unmarshal(jsonBytes, &vars); err != nil {
+		return nil, fmt.Errorf("writing json: %w", err)
+	}
+
+	return vars, nil
+}
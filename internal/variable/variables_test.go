@@ -80,3 +80,74 @@ func TestLoad(t *testing.T) {
 	is.Equal(vars["untyped_string_value"].StringValue, "untyped_string_value")
 	is.Equal(vars["untyped_string_value"].AnyValue, cty.StringVal("untyped_string_value"))
 }
+
+func TestSetAnyValue(t *testing.T) {
+	is := is.New(t)
+
+	// Arrange
+
+	vars := Variables{}
+	untyped, err := New("untyped", "", "")
+	is.NoErr(err)
+	vars["untyped"] = untyped
+	_, err = vars.SetValue("frozen", "original", true)
+	is.NoErr(err)
+
+	// Act
+
+	tags, tagsErr := vars.SetAnyValue("tags", map[string]interface{}{"team": "platform"}, false)
+	name, nameErr := vars.SetAnyValue("name", "example", false)
+	_, untypedErr := vars.SetAnyValue("untyped", []interface{}{"a"}, false)
+	_, frozenErr := vars.SetAnyValue("frozen", "changed", false)
+
+	// Assert
+
+	is.NoErr(tagsErr)
+	is.Equal(tags.StringValue, `{"team":"platform"}`)
+	is.Equal(tags.AnyValue, cty.ObjectVal(map[string]cty.Value{"team": cty.StringVal("platform")}))
+
+	is.NoErr(nameErr)
+	is.Equal(name.AnyValue, cty.StringVal("example"))
+
+	is.NoErr(untypedErr)
+	is.Equal(vars["untyped"].AnyValue, cty.StringVal(`["a"]`)) // existing variables keep their type
+
+	is.True(frozenErr != nil)
+	is.Equal(frozenErr.Error(), "cannot change frozen variable frozen")
+}
+
+func TestLoadTofu(t *testing.T) {
+	is := is.New(t)
+
+	// Arrange
+
+	tempDir, err := os.MkdirTemp("", "ltf-test-")
+	is.NoErr(err) // error creating temporary directory
+	defer os.RemoveAll(tempDir)
+
+	files := map[string]string{
+		"main.tf":        `variable "ignored" {}`,
+		"main.tofu":      `variable "tofu" { default = "tofu" }`,
+		"other.tf":       `variable "terraform" { default = "terraform" }`,
+		"json.tofu.json": `{"variable": {"json": {"default": "json"}}}`,
+	}
+	for name, contents := range files {
+		err = ioutil.WriteFile(path.Join(tempDir, name), []byte(contents), 06666)
+		is.NoErr(err) // error creating file
+	}
+
+	args, err := arguments.New([]string{"ltf"}, ltf.NewEnviron())
+	is.NoErr(err) // error creating arguments
+
+	// Act
+
+	vars, err := Load(args, []string{tempDir}, tempDir)
+	is.NoErr(err) // error loading variables
+
+	// Assert
+
+	is.Equal(vars["tofu"].StringValue, "tofu")
+	is.Equal(vars["terraform"].StringValue, "terraform")
+	is.Equal(vars["json"].StringValue, "json")
+	is.True(vars["ignored"] == nil) // main.tf is replaced by main.tofu
+}
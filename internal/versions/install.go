@@ -0,0 +1,196 @@
+package versions
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-version"
+)
+
+// client is used to download from the mirror.
+var client = &http.Client{Timeout: 10 * time.Minute}
+
+// goos and goarch are replaced in tests to choose which builds to install.
+var (
+	goos   = runtime.GOOS
+	goarch = runtime.GOARCH
+)
+
+// index is the format of the index.json file of a mirror,
+// which is the same as https://releases.hashicorp.com/terraform/index.json.
+type index struct {
+	Versions map[string]struct {
+		Shasums string `json:"shasums"`
+		Builds  []struct {
+			OS       string `json:"os"`
+			Arch     string `json:"arch"`
+			Filename string `json:"filename"`
+		} `json:"builds"`
+	} `json:"versions"`
+}
+
+// Install downloads the newest Terraform version from the mirror that matches
+// the required_version constraints, and extracts it into the versions directory.
+// It returns the path of the Terraform binary and the version that was installed.
+func (v *Versions) Install(required []string) (string, string, error) {
+	if v.Mirror == "" {
+		return "", "", fmt.Errorf("no versions mirror is configured")
+	}
+	constraints, err := parseConstraints(required)
+	if err != nil {
+		return "", "", err
+	}
+	dir, err := v.dir()
+	if err != nil {
+		return "", "", err
+	}
+	mirror := strings.TrimSuffix(v.Mirror, "/")
+
+	// Find the newest matching version with a build for this platform.
+	body, err := download(mirror + "/index.json")
+	if err != nil {
+		return "", "", err
+	}
+	idx := index{}
+	if err := json.Unmarshal(body, &idx); err != nil {
+		return "", "", fmt.Errorf("reading %s/index.json: %w", mirror, err)
+	}
+	available := version.Collection{}
+	for name := range idx.Versions {
+		if ver, err := version.NewVersion(name); err == nil && constraints.Check(ver) {
+			available = append(available, ver)
+		}
+	}
+	sort.Sort(sort.Reverse(available))
+	var ver *version.Version
+	filename := ""
+	for _, candidate := range available {
+		for _, build := range idx.Versions[candidate.Original()].Builds {
+			if build.OS == goos && build.Arch == goarch {
+				ver = candidate
+				filename = build.Filename
+				break
+			}
+		}
+		if ver != nil {
+			break
+		}
+	}
+	if ver == nil {
+		return "", "", fmt.Errorf("no Terraform version in %s matches required_version %q for %s_%s", mirror, constraints.String(), goos, goarch)
+	}
+	base := mirror + "/" + ver.Original()
+
+	// Download the archive and check it against the checksums file.
+	// Nothing is installed without a checksums file.
+	shasums := idx.Versions[ver.Original()].Shasums
+	if shasums == "" {
+		return "", "", fmt.Errorf("no checksums file for Terraform %s in %s/index.json", ver.Original(), mirror)
+	}
+	archive, err := download(base + "/" + filename)
+	if err != nil {
+		return "", "", err
+	}
+	sums, err := download(base + "/" + shasums)
+	if err != nil {
+		return "", "", err
+	}
+	if err := verify(archive, filename, sums); err != nil {
+		return "", "", err
+	}
+
+	// Extract the binary, writing to a temporary file first
+	// so an interrupted install does not leave a broken binary.
+	binary := binaryPath(dir, ver)
+	if err := os.MkdirAll(filepath.Dir(binary), 0755); err != nil {
+		return "", "", err
+	}
+	if err := extract(archive, binary); err != nil {
+		return "", "", fmt.Errorf("extracting %s: %w", filename, err)
+	}
+	return binary, ver.Original(), nil
+}
+
+// download returns the body of a URL.
+func download(url string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("downloading %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading %s: %s", url, resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("downloading %s: %w", url, err)
+	}
+	return body, nil
+}
+
+// verify checks the SHA256 checksum of a file against a SHA256SUMS file.
+func verify(content []byte, filename string, sums []byte) error {
+	sum := sha256.Sum256(content)
+	actual := hex.EncodeToString(sum[:])
+	scanner := bufio.NewScanner(bytes.NewReader(sums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[1] == filename {
+			if fields[0] != actual {
+				return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", filename, fields[0], actual)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("checksum not found for %s", filename)
+}
+
+// extract writes the terraform binary from a zip archive to a file.
+func extract(archive []byte, binary string) error {
+	r, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return err
+	}
+	for _, f := range r.File {
+		if f.Name != "terraform" {
+			continue
+		}
+		src, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+
+		tmp, err := ioutil.TempFile(filepath.Dir(binary), ".terraform-")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		if _, err := io.Copy(tmp, src); err != nil {
+			tmp.Close()
+			return err
+		}
+		if err := tmp.Close(); err != nil {
+			return err
+		}
+		if err := os.Chmod(tmp.Name(), 0755); err != nil {
+			return err
+		}
+		return os.Rename(tmp.Name(), binary)
+	}
+	return fmt.Errorf("terraform binary not found in archive")
+}
@@ -0,0 +1,153 @@
+package versions
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+// setPlatform changes the platform of the builds to install during a test.
+func setPlatform(t *testing.T, os string, arch string) {
+	oldOS, oldArch := goos, goarch
+	goos, goarch = os, arch
+	t.Cleanup(func() {
+		goos, goarch = oldOS, oldArch
+	})
+}
+
+// zipBinary returns a zip archive containing a terraform file.
+func zipBinary(t *testing.T, content string) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	f, err := w.Create("terraform")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// serveMirror starts a mirror with the same layout as releases.hashicorp.com.
+func serveMirror(t *testing.T, files map[string][]byte) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if content, ok := files[r.URL.Path]; ok {
+			w.Write(content)
+		} else {
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server.URL + "/terraform"
+}
+
+func TestInstall(t *testing.T) {
+	is := is.New(t)
+
+	// Arrange
+
+	setPlatform(t, "linux", "amd64")
+
+	archive := zipBinary(t, "terraform 1.5.7")
+	sum := sha256.Sum256(archive)
+	mirror := serveMirror(t, map[string][]byte{
+		"/terraform/index.json": []byte(`{"versions": {
+			"1.5.7": {"shasums": "terraform_1.5.7_SHA256SUMS", "builds": [
+				{"os": "darwin", "arch": "arm64", "filename": "terraform_1.5.7_darwin_arm64.zip"},
+				{"os": "linux", "arch": "amd64", "filename": "terraform_1.5.7_linux_amd64.zip"}
+			]},
+			"1.6.0": {"shasums": "terraform_1.6.0_SHA256SUMS", "builds": [
+				{"os": "linux", "arch": "amd64", "filename": "terraform_1.6.0_linux_amd64.zip"}
+			]}
+		}}`),
+		"/terraform/1.5.7/terraform_1.5.7_linux_amd64.zip": archive,
+		"/terraform/1.5.7/terraform_1.5.7_SHA256SUMS":      []byte(fmt.Sprintf("%s  terraform_1.5.7_linux_amd64.zip\n", hex.EncodeToString(sum[:]))),
+	})
+	v := &Versions{Dir: t.TempDir(), Mirror: mirror}
+
+	// Act
+
+	binary, installed, err := v.Install([]string{"~> 1.5.0"})
+
+	// Assert
+
+	is.NoErr(err)
+	is.Equal(installed, "1.5.7")
+	is.Equal(binary, filepath.Join(v.Dir, "1.5.7", "terraform"))
+	content, err := os.ReadFile(binary)
+	is.NoErr(err)
+	is.Equal(string(content), "terraform 1.5.7")
+
+	found, err := v.Find([]string{"~> 1.5.0"})
+	is.NoErr(err)
+	is.Equal(found, binary) // installed versions are found afterwards
+}
+
+func TestInstallChecksumMismatch(t *testing.T) {
+	is := is.New(t)
+
+	// Arrange
+
+	setPlatform(t, "linux", "amd64")
+
+	mirror := serveMirror(t, map[string][]byte{
+		"/terraform/index.json": []byte(`{"versions": {"1.5.7": {"shasums": "terraform_1.5.7_SHA256SUMS", "builds": [
+			{"os": "linux", "arch": "amd64", "filename": "terraform_1.5.7_linux_amd64.zip"}
+		]}}}`),
+		"/terraform/1.5.7/terraform_1.5.7_linux_amd64.zip": zipBinary(t, "tampered"),
+		"/terraform/1.5.7/terraform_1.5.7_SHA256SUMS":      []byte("0000  terraform_1.5.7_linux_amd64.zip\n"),
+	})
+	v := &Versions{Dir: t.TempDir(), Mirror: mirror}
+
+	// Act
+
+	_, _, err := v.Install(nil)
+
+	// Assert
+
+	is.True(err != nil)
+	is.True(strings.HasPrefix(err.Error(), "checksum mismatch for terraform_1.5.7_linux_amd64.zip"))
+	_, statErr := os.Stat(filepath.Join(v.Dir, "1.5.7", "terraform"))
+	is.True(os.IsNotExist(statErr)) // nothing is installed
+}
+
+func TestInstallWithoutChecksums(t *testing.T) {
+	is := is.New(t)
+
+	// Arrange
+
+	setPlatform(t, "linux", "amd64")
+
+	mirror := serveMirror(t, map[string][]byte{
+		"/terraform/index.json": []byte(`{"versions": {"1.5.7": {"builds": [
+			{"os": "linux", "arch": "amd64", "filename": "terraform_1.5.7_linux_amd64.zip"}
+		]}}}`),
+		"/terraform/1.5.7/terraform_1.5.7_linux_amd64.zip": zipBinary(t, "unverified"),
+	})
+	v := &Versions{Dir: t.TempDir(), Mirror: mirror}
+
+	// Act
+
+	_, _, err := v.Install(nil)
+
+	// Assert
+
+	is.True(err != nil)
+	is.True(strings.HasPrefix(err.Error(), "no checksums file for Terraform 1.5.7"))
+	_, statErr := os.Stat(filepath.Join(v.Dir, "1.5.7", "terraform"))
+	is.True(os.IsNotExist(statErr)) // nothing is installed
+}
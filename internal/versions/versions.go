@@ -0,0 +1,149 @@
+// Package versions finds Terraform binaries that match the required_version
+// constraints of a configuration, and installs them from a mirror.
+package versions
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+)
+
+// DefaultDir is the versions directory used when none is configured.
+const DefaultDir = "~/.ltf/versions"
+
+// Versions configures how LTF finds a Terraform binary that matches the
+// required_version constraints of the configuration.
+type Versions struct {
+	// Dir is the directory containing Terraform binaries, with the layout
+	// $dir/$version/terraform. It defaults to ~/.ltf/versions.
+	Dir string `yaml:"dir,omitempty"`
+
+	// Mirror is the URL of a mirror of https://releases.hashicorp.com/terraform,
+	// used to install a matching version when none are found in Dir.
+	Mirror string `yaml:"mirror,omitempty"`
+}
+
+// Validate returns an error if the versions settings are invalid.
+func (v *Versions) Validate() error {
+	if v.Mirror != "" {
+		u, err := url.Parse(v.Mirror)
+		if err != nil {
+			return fmt.Errorf("invalid versions mirror: %w", err)
+		}
+		if u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("invalid versions mirror %q: must be an https URL", v.Mirror)
+		}
+	}
+	return nil
+}
+
+// ConstraintError is returned when no Terraform binary matches the constraints.
+type ConstraintError struct {
+	Constraints version.Constraints
+	Dir         string
+	Installed   version.Collection
+}
+
+func (e *ConstraintError) Error() string {
+	installed := []string{}
+	for _, v := range e.Installed {
+		installed = append(installed, v.String())
+	}
+	if len(e.Constraints) == 0 {
+		return fmt.Sprintf("no Terraform versions found in %s", e.Dir)
+	}
+	if len(installed) == 0 {
+		return fmt.Sprintf("no Terraform version matches required_version %q: no versions found in %s", e.Constraints.String(), e.Dir)
+	}
+	return fmt.Sprintf("no Terraform version matches required_version %q: found %s in %s", e.Constraints.String(), strings.Join(installed, ", "), e.Dir)
+}
+
+// Find returns the path of the newest Terraform binary in the versions
+// directory that matches the required_version constraints, or a
+// ConstraintError if none match.
+func (v *Versions) Find(required []string) (string, error) {
+	constraints, err := parseConstraints(required)
+	if err != nil {
+		return "", err
+	}
+	dir, err := v.dir()
+	if err != nil {
+		return "", err
+	}
+
+	installed, err := installedVersions(dir)
+	if err != nil {
+		return "", err
+	}
+	for i := len(installed) - 1; i >= 0; i-- {
+		if constraints.Check(installed[i]) {
+			return binaryPath(dir, installed[i]), nil
+		}
+	}
+	return "", &ConstraintError{Constraints: constraints, Dir: dir, Installed: installed}
+}
+
+// dir returns the versions directory, with ~ expanded to the home directory.
+func (v *Versions) dir() (string, error) {
+	dir := v.Dir
+	if dir == "" {
+		dir = DefaultDir
+	}
+	if dir == "~" || strings.HasPrefix(dir, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("finding versions directory: %w", err)
+		}
+		dir = filepath.Join(home, dir[1:])
+	}
+	return dir, nil
+}
+
+// parseConstraints combines the required_version constraints
+// from all files in the configuration.
+func parseConstraints(required []string) (version.Constraints, error) {
+	constraints := version.Constraints{}
+	for _, r := range required {
+		c, err := version.NewConstraint(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid required_version %q: %w", r, err)
+		}
+		constraints = append(constraints, c...)
+	}
+	return constraints, nil
+}
+
+// installedVersions returns the versions in the versions directory
+// that contain a Terraform binary, sorted from oldest to newest.
+func installedVersions(dir string) (version.Collection, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return version.Collection{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	installed := version.Collection{}
+	for _, entry := range entries {
+		v, err := version.NewVersion(entry.Name())
+		if err != nil {
+			continue
+		}
+		if _, err := os.Stat(binaryPath(dir, v)); err != nil {
+			continue
+		}
+		installed = append(installed, v)
+	}
+	sort.Sort(installed)
+	return installed, nil
+}
+
+// binaryPath returns the path of the Terraform binary for a version.
+func binaryPath(dir string, v *version.Version) string {
+	return filepath.Join(dir, v.Original(), "terraform")
+}
@@ -0,0 +1,76 @@
+package versions
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+// writeVersions creates a versions directory with a fake binary for each version.
+func writeVersions(t *testing.T, versions ...string) string {
+	dir := t.TempDir()
+	for _, v := range versions {
+		if err := os.MkdirAll(filepath.Join(dir, v), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, v, "terraform"), []byte(v), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestFind(t *testing.T) {
+	is := is.New(t)
+
+	// Arrange
+
+	dir := writeVersions(t, "1.4.6", "1.5.0", "1.5.7", "1.6.0")
+	if err := os.MkdirAll(filepath.Join(dir, "1.7.0"), 0755); err != nil {
+		t.Fatal(err) // a directory without a binary is ignored
+	}
+	v := &Versions{Dir: dir}
+
+	// Act
+
+	newest, newestErr := v.Find(nil)
+	matched, matchedErr := v.Find([]string{">= 1.5", "~> 1.5.0"})
+	_, missingErr := v.Find([]string{"~> 1.3.0"})
+	_, invalidErr := v.Find([]string{"one"})
+
+	// Assert
+
+	is.NoErr(newestErr)
+	is.Equal(newest, filepath.Join(dir, "1.6.0", "terraform"))
+
+	is.NoErr(matchedErr)
+	is.Equal(matched, filepath.Join(dir, "1.5.7", "terraform"))
+
+	var constraintErr *ConstraintError
+	is.True(errors.As(missingErr, &constraintErr))
+	is.Equal(missingErr.Error(), `no Terraform version matches required_version "~> 1.3.0": found 1.4.6, 1.5.0, 1.5.7, 1.6.0 in `+dir)
+
+	is.True(invalidErr != nil)
+}
+
+func TestFindEmptyDir(t *testing.T) {
+	is := is.New(t)
+
+	v := &Versions{Dir: filepath.Join(t.TempDir(), "missing")}
+
+	_, err := v.Find([]string{"1.5.7"})
+
+	is.Equal(err.Error(), `no Terraform version matches required_version "1.5.7": no versions found in `+v.Dir)
+}
+
+func TestValidate(t *testing.T) {
+	is := is.New(t)
+
+	is.NoErr((&Versions{}).Validate())
+	is.NoErr((&Versions{Mirror: "https://releases.hashicorp.com/terraform"}).Validate())
+	is.True((&Versions{Mirror: "http://releases.hashicorp.com/terraform"}).Validate() != nil) // not https
+	is.True((&Versions{Mirror: "releases.hashicorp.com/terraform"}).Validate() != nil)        // not a URL
+}